@@ -0,0 +1,165 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePlaylistAudioZip extracts the audio of every playlist item and sends
+// them as a single ZIP named after the playlist, for albums and podcast
+// series. When the archive would be over Telegram's limit, the tracks are sent
+// one by one instead.
+func handlePlaylistAudioZip(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, statusMsgID int) {
+	if !requireFFmpeg(bot, chatID) {
+		return
+	}
+
+	dir := filePrefix("album", time.Now().UnixNano())
+	defer os.RemoveAll(dir)
+
+	// The playlist title becomes the directory the tracks land in, which is how
+	// the archive gets its name without a separate metadata fetch
+	ytdlpArgs := []string{
+		"-x",
+		"--audio-format", "mp3",
+		"--audio-quality", "0",
+		"-o", filepath.Join(dir, "%(playlist_title)s", "%(playlist_index)03d - %(title)s.%(ext)s"),
+		"--newline",
+		"--progress-template", "%(progress.downloaded_bytes)s/%(progress.total_bytes)s",
+		"--yes-playlist",
+		"--playlist-end", strconv.Itoa(MaxPlaylistItems),
+		"--ignore-errors",
+	}
+	ytdlpArgs = append(ytdlpArgs, musicArgs(info)...)
+
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, "playlist audio")
+
+	tracks, _ := filepath.Glob(filepath.Join(dir, "*", "*.mp3"))
+	sort.Strings(tracks)
+	if len(tracks) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no files downloaded")
+		}
+		reportDownloadFailure(bot, chatID, info, "playlist audio download", err, output)
+		return
+	}
+
+	tracks = filterByPostDownloadHook(runner, info, tracks)
+	if len(tracks) == 0 {
+		sendFailure(bot, chatID, hookRejectedMessage)
+		return
+	}
+
+	album := filepath.Base(filepath.Dir(tracks[0]))
+	archive := filepath.Join(dir, sanitizeFilename(album)+".zip")
+	if err := zipFiles(archive, tracks); err != nil {
+		jobLog(info).Println("Failed to create playlist archive:", err)
+		sendPlaylistTracks(bot, chatID, info, tracks)
+		return
+	}
+
+	stat, err := os.Stat(archive)
+	if err != nil || stat.Size() > MaxFileSize {
+		bot.Send(tgbotapi.NewMessage(chatID, "📦 The playlist is too large for a single archive, sending the tracks one by one."))
+		sendPlaylistTracks(bot, chatID, info, tracks)
+		return
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+		fmt.Sprintf("✅ "+bold("Playlist audio downloaded!")+"\n\nUploading %d track(s) as a ZIP...", len(tracks)))
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+
+	file, err := os.Open(archive)
+	if err != nil {
+		jobLog(info).Println("Failed to open playlist archive:", err)
+		sendFailure(bot, chatID, "❌ Failed to send the playlist archive.")
+		return
+	}
+	defer file.Close()
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: filepath.Base(archive), Reader: file})
+	document.Caption = fmt.Sprintf("🗜 %s\n▫️ Tracks: %d\n▫️ Size: %.1f MB", album, len(tracks), float64(stat.Size())/1048576)
+	if _, err := sendWithRetry(bot, document, file); err != nil {
+		jobLog(info).Println("Failed to send playlist archive:", err)
+		sendFailure(bot, chatID, "❌ Failed to send the playlist archive.")
+		return
+	}
+	downloadSucceeded(chatID)
+}
+
+// zipFiles stores files in a new archive at path. MP3s don't compress, so they
+// are stored as they are.
+func zipFiles(path string, files []string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	for _, name := range files {
+		if err := addToZip(archive, name); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func addToZip(archive *zip.Writer, name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: filepath.Base(name), Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, in)
+	return err
+}
+
+// sendPlaylistTracks is the fallback that sends each track as its own audio message
+func sendPlaylistTracks(bot *tgbotapi.BotAPI, chatID int64, info Download, tracks []string) {
+	skipped := 0
+	for _, track := range tracks {
+		stat, err := os.Stat(track)
+		if err != nil || stat.Size() > MaxFileSize {
+			skipped++
+			continue
+		}
+		file, err := os.Open(track)
+		if err != nil {
+			skipped++
+			continue
+		}
+		audio := tgbotapi.NewAudio(chatID, tgbotapi.FileReader{Name: filepath.Base(track), Reader: file})
+		audio.Title = strings.TrimSuffix(filepath.Base(track), filepath.Ext(track))
+		_, err = sendWithRetry(bot, audio, file)
+		file.Close()
+		if err != nil {
+			jobLog(info).Println("Failed to send playlist track:", err)
+			skipped++
+		}
+	}
+
+	if skipped > 0 {
+		sendFailure(bot, chatID, fmt.Sprintf("⚠️ %d of %d tracks could not be sent.", skipped, len(tracks)))
+		return
+	}
+	downloadSucceeded(chatID)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxAudioSources caps how many audio formats the source picker offers
+const MaxAudioSources = 6
+
+// audioSourcePrefix marks an audio quality that names a source format, as in "src251"
+const audioSourcePrefix = "src"
+
+// audioSources lists a video's audio-only formats, highest bitrate first, with
+// one entry per codec and bitrate
+func audioSources(meta *VideoMetadata) []VideoFormat {
+	if meta == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var sources []VideoFormat
+	for _, f := range meta.Formats {
+		// Callback data is split on ":" and limited to 64 bytes
+		if !f.HasAudio() || f.HasVideo() || f.ABR <= 0 || strings.Contains(f.FormatID, ":") || len(f.FormatID) > 40 {
+			continue
+		}
+		key := fmt.Sprintf("%s/%.0f", audioCodecName(f.ACodec), math.Round(f.ABR))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		sources = append(sources, f)
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].ABR > sources[j].ABR })
+	if len(sources) > MaxAudioSources {
+		sources = sources[:MaxAudioSources]
+	}
+	return sources
+}
+
+// audioCodecName shortens yt-dlp codec strings such as "mp4a.40.2" to "aac"
+func audioCodecName(codec string) string {
+	switch {
+	case strings.HasPrefix(codec, "mp4a"):
+		return "aac"
+	case codec == "":
+		return "unknown"
+	}
+	return codec
+}
+
+// audioSourceID extracts the format ID from a source quality like "src251"
+func audioSourceID(quality string) (string, bool) {
+	id := strings.TrimPrefix(quality, audioSourcePrefix)
+	return id, id != quality && id != ""
+}
+
+// createAudioSourceRow opens the source picker when there is more than one
+// audio format to choose from
+func createAudioSourceRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if len(audioSources(info.Meta)) < 2 {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🎧 Pick audio source", "audiosrc:pick"))
+}
+
+// createAudioSourceKeyboard offers each audio format by codec and bitrate
+func createAudioSourceKeyboard(info Download) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, f := range audioSources(info.Meta) {
+		label := fmt.Sprintf("🎧 %s %.0fk (%s)", audioCodecName(f.ACodec), f.ABR, f.Ext)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "audio:"+audioSourcePrefix+f.FormatID)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", "menu:formats")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// withAudioSource extracts exactly the given audio format, keeping its codec
+func withAudioSource(args []string, formatID string) []string {
+	args = withOriginalAudio(args)
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-f" {
+			args[i+1] = formatID
+		}
+	}
+	return args
+}
+
+// probeAudioStream reports the codec and bitrate (in kbit/s, 0 when unknown)
+// of a file's first audio stream, for the caption
+func probeAudioStream(runner Runner, path string) (string, int, error) {
+	output, err := runner.Run(context.Background(), "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,bit_rate:format=bit_rate",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		return "", 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+			BitRate   string `json:"bit_rate"`
+		} `json:"streams"`
+		Format struct {
+			BitRate string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return "", 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return "", 0, fmt.Errorf("no audio stream found")
+	}
+
+	// Containers such as webm only record the bitrate for the whole file
+	bitRate := probe.Streams[0].BitRate
+	if _, err := strconv.Atoi(bitRate); err != nil {
+		bitRate = probe.Format.BitRate
+	}
+	bps, _ := strconv.Atoi(bitRate)
+	return probe.Streams[0].CodecName, (bps + 500) / 1000, nil
+}
+
+// audioStreamNote describes the sent audio's codec and bitrate in a caption
+func audioStreamNote(runner Runner, info Download, path string) string {
+	codec, kbps, err := probeAudioStream(runner, path)
+	if err != nil {
+		jobLog(info).Println("Failed to probe audio stream:", err)
+		return ""
+	}
+	if kbps == 0 {
+		return "\n▫️ Audio: " + escapeText(codec)
+	}
+	return fmt.Sprintf("\n▫️ Audio: %s, %d kbps", escapeText(codec), kbps)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// A long title makes buildCaption render the caption several times while it
+// fits the title, which must not mean probing the file every time
+func TestAudioCaptionProbesOnce(t *testing.T) {
+	withParseMode(t, ParseModeMarkdown)
+	runner := &fakeRunner{respond: func(call fakeCall) (string, error) {
+		switch call.name {
+		case "yt-dlp":
+			output, _ := flagValue(call.args, "-o")
+			path := strings.Replace(output, "%(ext)s", "mp3", 1)
+			t.Cleanup(func() { os.Remove(path) })
+			return "", os.WriteFile(path, []byte("audio"), 0o644)
+		case "ffprobe":
+			return `{"streams":[{"codec_name":"mp3","bit_rate":"192000"}]}`, nil
+		}
+		return "", nil
+	}}
+
+	info := Download{Platform: "Vimeo", URL: "https://vimeo.com/1", Title: strings.Repeat("long title ", 500)}
+	handleAudioDownload(newTestBot(t), runner, 1, info, "mp3", 1)
+
+	if calls := runner.commands("yt-dlp"); len(calls) != 1 {
+		t.Fatalf("yt-dlp ran %d times, want 1", len(calls))
+	}
+	streamProbes := 0
+	for _, args := range runner.commands("ffprobe") {
+		if selected, _ := flagValue(args, "-select_streams"); selected == "a:0" {
+			streamProbes++
+		}
+	}
+	if streamProbes != 1 {
+		t.Errorf("the audio stream was probed %d times, want 1", streamProbes)
+	}
+}
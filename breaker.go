@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Circuit breaker settings: after BreakerThreshold consecutive server-side
+// failures within BreakerWindow, a platform is switched off for BreakerCooldown.
+// After that a single request is let through as a probe; if it works the
+// platform is back, if not the cooldown starts over.
+const (
+	BreakerThreshold = 5
+	BreakerWindow    = 10 * time.Minute
+	BreakerCooldown  = 5 * time.Minute
+)
+
+type breakerState struct {
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time // zero while closed
+	probing      time.Time // when the current probe was let through
+}
+
+// platformBreakers tracks extractor health per platform
+type platformBreakers struct {
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+var breakers = &platformBreakers{states: make(map[string]*breakerState)}
+
+func (b *platformBreakers) state(platform string) *breakerState {
+	state, ok := b.states[platform]
+	if !ok {
+		state = &breakerState{}
+		b.states[platform] = state
+	}
+	return state
+}
+
+// allow reports whether a new request for the platform may go ahead
+func (b *platformBreakers) allow(platform string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state(platform)
+	if state.openedAt.IsZero() || time.Since(state.openedAt) < BreakerCooldown {
+		return state.openedAt.IsZero()
+	}
+	// Cooled down: let one probe through, or another if the last one never
+	// reported back
+	if state.probing.IsZero() || time.Since(state.probing) >= BreakerCooldown {
+		state.probing = time.Now()
+		return true
+	}
+	return false
+}
+
+// success closes the platform's breaker
+func (b *platformBreakers) success(platform string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state(platform)
+	if !state.openedAt.IsZero() {
+		log.Printf("%s downloads are working again, closing the circuit breaker", platform)
+	}
+	*state = breakerState{}
+}
+
+// failure records a server-side failure, opening the breaker once there have
+// been too many in a row
+func (b *platformBreakers) failure(platform string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state(platform)
+	if !state.openedAt.IsZero() {
+		// A failed probe: start the cooldown over
+		state.openedAt = time.Now()
+		state.probing = time.Time{}
+		return
+	}
+
+	if state.failures == 0 || time.Since(state.firstFailure) > BreakerWindow {
+		state.failures = 0
+		state.firstFailure = time.Now()
+	}
+	state.failures++
+	if state.failures < BreakerThreshold {
+		return
+	}
+
+	state.openedAt = time.Now()
+	log.Printf("%s downloads failed %d times in a row, opening the circuit breaker for %s", platform, state.failures, BreakerCooldown)
+}
+
+// recordDownloadResult feeds a finished yt-dlp run into the platform's breaker.
+// Only server-side failures count; a private video says nothing about whether
+// the extractor works.
+func recordDownloadResult(platform string, err error, output string) {
+	if err == nil {
+		breakers.success(platform)
+		return
+	}
+	if failure, _ := classifyFailure(err, output); failure.Kind == ServerFailure {
+		breakers.failure(platform)
+	}
+}
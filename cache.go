@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PromptTTL is how long a format prompt's buttons keep working. Older entries
+// are dropped so the cache doesn't grow for as long as the bot runs.
+const PromptTTL = 24 * time.Hour
+
+// downloadCache holds the link behind each format prompt, keyed by getCacheKey,
+// and remembers which prompts already have a download running so a double tap
+// doesn't start a second one
+type downloadCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedDownload
+	active  map[string]bool
+}
+
+// cachedDownload is a prompt's link along with when it was last stored
+type cachedDownload struct {
+	info  Download
+	added time.Time
+}
+
+var urlCache = &downloadCache{
+	entries: make(map[string]cachedDownload),
+	active:  make(map[string]bool),
+}
+
+func (c *downloadCache) get(key string) (Download, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry.info, ok
+}
+
+func (c *downloadCache) set(key string, info Download) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cachedDownload{info: info, added: time.Now()}
+}
+
+// isActive reports whether a download is running for the prompt
+func (c *downloadCache) isActive(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active[key]
+}
+
+// tryStart marks the prompt as having a download running. It returns false if
+// one already was.
+func (c *downloadCache) tryStart(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[key] {
+		return false
+	}
+	c.active[key] = true
+	return true
+}
+
+// finish allows new downloads from the prompt again
+func (c *downloadCache) finish(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.active, key)
+}
+
+// purge forgets every prompt without a running download and returns how many
+// were dropped. Running downloads keep their entries so they finish normally.
+func (c *downloadCache) purge() int {
+	return c.expire(0)
+}
+
+// expire forgets the prompts stored more than maxAge ago, except those with a
+// running download, and returns how many were dropped
+func (c *downloadCache) expire(maxAge time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, entry := range c.entries {
+		if !c.active[key] && time.Since(entry.added) >= maxAge {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// startCacheSweeper periodically drops prompts older than PromptTTL
+func startCacheSweeper(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			urlCache.expire(PromptTTL)
+		}
+	}()
+}
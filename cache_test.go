@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDownloadCacheExpire(t *testing.T) {
+	cache := &downloadCache{entries: make(map[string]cachedDownload), active: make(map[string]bool)}
+	cache.set("fresh", Download{URL: "https://vimeo.com/1"})
+	cache.set("stale", Download{URL: "https://vimeo.com/2"})
+	cache.set("running", Download{URL: "https://vimeo.com/3"})
+	for _, key := range []string{"stale", "running"} {
+		entry := cache.entries[key]
+		entry.added = time.Now().Add(-2 * PromptTTL)
+		cache.entries[key] = entry
+	}
+	cache.tryStart("running")
+
+	if removed := cache.expire(PromptTTL); removed != 1 {
+		t.Errorf("expire dropped %d prompts, want 1", removed)
+	}
+	for key, want := range map[string]bool{"fresh": true, "stale": false, "running": true} {
+		if _, ok := cache.get(key); ok != want {
+			t.Errorf("%s prompt kept = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+// Only the languages of the caption tracks are kept from yt-dlp's output
+func TestCaptionTracksKeepLanguages(t *testing.T) {
+	data := `{"subtitles":{"en":[{"ext":"vtt","url":"https://example.com/en.vtt"}]},
+		"automatic_captions":{"de-orig":[{"ext":"srv3","url":"https://example.com/de"}],"fr":[]},
+		"language":"de"}`
+	var meta VideoMetadata
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Subtitles) != 1 || len(meta.AutomaticCaptions) != 2 {
+		t.Fatalf("decoded %d subtitle and %d caption languages, want 1 and 2", len(meta.Subtitles), len(meta.AutomaticCaptions))
+	}
+	if got := transcriptLanguages(&meta); len(got) != 2 || got[0] != "en" || got[1] != "de-orig" {
+		t.Errorf("transcriptLanguages = %q, want [en de-orig]", got)
+	}
+}
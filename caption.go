@@ -0,0 +1,126 @@
+package main
+
+import (
+	"html"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram limits, counted in characters
+const (
+	MaxCaptionLength = 1024
+	MaxMessageLength = 4096
+)
+
+// Parse modes the bot can format its messages in
+const (
+	ParseModeMarkdown = tgbotapi.ModeMarkdown
+	ParseModeHTML     = tgbotapi.ModeHTML
+)
+
+// parseMode is read from PARSE_MODE ("Markdown" or "HTML") at startup. Legacy
+// Markdown stays the default; HTML copes better with arbitrary titles.
+var parseMode = loadParseMode()
+
+func loadParseMode() string {
+	switch mode := os.Getenv("PARSE_MODE"); {
+	case mode == "":
+		return ParseModeMarkdown
+	case strings.EqualFold(mode, ParseModeHTML):
+		return ParseModeHTML
+	case strings.EqualFold(mode, ParseModeMarkdown):
+		return ParseModeMarkdown
+	default:
+		log.Printf("Unknown PARSE_MODE %q, using %s", mode, ParseModeMarkdown)
+		return ParseModeMarkdown
+	}
+}
+
+// bold marks text as bold in the configured parse mode. The text itself is not
+// escaped, so it may contain format verbs.
+func bold(text string) string {
+	if parseMode == ParseModeHTML {
+		return "<b>" + text + "</b>"
+	}
+	return "*" + text + "*"
+}
+
+// fitTitle renders a text around a title and, if the result is longer than limit,
+// shortens only the title until the whole text fits. The title is escaped before
+// it is measured, since escaping makes it longer, and it is cut so that no escape
+// sequence is split in half.
+func fitTitle(limit int, title string, render func(title string) string) string {
+	escaped := escapeText(title)
+	text := render(escaped)
+	for {
+		overflow := utf8.RuneCountInString(text) - limit
+		if overflow <= 0 {
+			return text
+		}
+
+		titleLen := utf8.RuneCountInString(escaped)
+		if titleLen == 0 {
+			// The template alone is too long; nothing left to trim from the title
+			return truncateString(text, limit)
+		}
+
+		// Leave room for the "..." that truncateEscaped appends
+		keep := titleLen - overflow
+		if keep < 4 {
+			escaped = ""
+		} else {
+			escaped = truncateEscaped(escaped, keep)
+		}
+		text = render(escaped)
+	}
+}
+
+// escapeText escapes dynamic content for the configured parse mode
+func escapeText(s string) string {
+	if parseMode == ParseModeHTML {
+		return html.EscapeString(s)
+	}
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, s)
+}
+
+// truncateEscaped shortens an escaped string to at most maxLen runes including
+// the trailing "...", without leaving a dangling backslash or half an HTML
+// entity behind
+func truncateEscaped(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+
+	cut := runes[:maxLen-3]
+	if parseMode == ParseModeHTML {
+		// Drop an entity such as "&amp;" that the cut left unterminated
+		if amp := strings.LastIndex(string(cut), "&"); amp >= 0 && !strings.Contains(string(cut)[amp:], ";") {
+			return string(cut)[:amp] + "..."
+		}
+		return string(cut) + "..."
+	}
+	trailing := 0
+	for i := len(cut) - 1; i >= 0 && cut[i] == '\\'; i-- {
+		trailing++
+	}
+	if trailing%2 == 1 {
+		// The cut fell between a backslash and the character it escapes
+		cut = cut[:len(cut)-1]
+	}
+	return string(cut) + "..."
+}
+
+// buildCaption fits a media caption around a title
+func buildCaption(title string, render func(title string) string) string {
+	return fitTitle(MaxCaptionLength, title, render)
+}
+
+// buildMessage fits a text message around a title
+func buildMessage(title string, render func(title string) string) string {
+	return fitTitle(MaxMessageLength, title, render)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// withParseMode switches the parse mode for the rest of the test
+func withParseMode(t *testing.T, mode string) {
+	t.Helper()
+	previous := parseMode
+	parseMode = mode
+	t.Cleanup(func() { parseMode = previous })
+}
+
+// videoCaption renders a caption the way the video upload does
+func videoCaption(title string) string {
+	return fmt.Sprintf("📹 "+bold("%s")+" - %s\n▫️ Quality: %s\n▫️ Resolution: %dx%d\n▫️ Duration: %s\n▫️ Size: %.1f MB",
+		"YouTube", title, "720p", 1280, 720, "12:34", 48.2)
+}
+
+func TestBuildCaptionLongTitles(t *testing.T) {
+	template := utf8.RuneCountInString(videoCaption(""))
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{"short", "A short title"},
+		{"exactly fitting", strings.Repeat("a", MaxCaptionLength-template)},
+		{"one over", strings.Repeat("a", MaxCaptionLength-template+1)},
+		{"very long", strings.Repeat("long title ", 500)},
+		{"cyrillic", strings.Repeat("Очень длинное название видео ", 100)},
+		{"emoji", strings.Repeat("🎬🔥 ", 600)},
+		{"mixed scripts", strings.Repeat("日本語のタイトル — título — عنوان ", 60)},
+	}
+
+	for _, mode := range []string{ParseModeMarkdown, ParseModeHTML} {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				withParseMode(t, mode)
+				caption := buildCaption(tt.title, videoCaption)
+
+				if n := utf8.RuneCountInString(caption); n > MaxCaptionLength {
+					t.Errorf("caption is %d characters, over the %d limit", n, MaxCaptionLength)
+				}
+				if !utf8.ValidString(caption) {
+					t.Errorf("caption contains a split multi-byte character: %q", caption)
+				}
+				if strings.Contains(caption, "�") {
+					t.Errorf("caption contains a replacement character: %q", caption)
+				}
+
+				fits := utf8.RuneCountInString(videoCaption(escapeText(tt.title))) <= MaxCaptionLength
+				if fits && caption != videoCaption(escapeText(tt.title)) {
+					t.Errorf("a title that fits was changed:\n%s", caption)
+				}
+				if !fits && !strings.Contains(caption, "...") {
+					t.Errorf("a truncated title should end in \"...\":\n%s", caption)
+				}
+				// Only the title gives way; the rest of the caption is intact
+				if !strings.HasSuffix(caption, "▫️ Size: 48.2 MB") {
+					t.Errorf("the template was cut:\n%s", caption)
+				}
+			})
+		}
+	}
+}
+
+func TestFitTitleTemplateTooLong(t *testing.T) {
+	withParseMode(t, ParseModeMarkdown)
+	text := fitTitle(50, "title", func(title string) string {
+		return strings.Repeat("ж", 100) + title
+	})
+	if n := utf8.RuneCountInString(text); n > 50 {
+		t.Errorf("text is %d characters, over the limit of 50", n)
+	}
+	if !utf8.ValidString(text) {
+		t.Errorf("text contains a split multi-byte character: %q", text)
+	}
+}
+
+// danglingEscape reports whether an escaped string ends partway through an
+// escape sequence: a lone backslash in Markdown, an unterminated entity in HTML
+func danglingEscape(mode, s string) bool {
+	if mode == ParseModeHTML {
+		amp := strings.LastIndex(s, "&")
+		return amp >= 0 && !strings.Contains(s[amp:], ";")
+	}
+	trailing := len(s) - len(strings.TrimRight(s, `\`))
+	return trailing%2 == 1
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		mode, input, want string
+	}{
+		{ParseModeMarkdown, "plain title", "plain title"},
+		{ParseModeMarkdown, "a_b*c[d]`e(f)~g", "a\\_b\\*c\\[d]\\`e(f)~g"},
+		{ParseModeHTML, "plain title", "plain title"},
+		{ParseModeHTML, "<b>Tom & Jerry</b>", "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;"},
+	}
+
+	for _, tt := range tests {
+		withParseMode(t, tt.mode)
+		if got := escapeText(tt.input); got != tt.want {
+			t.Errorf("%s: escapeText(%q) = %q, want %q", tt.mode, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateEscapedBoundaries(t *testing.T) {
+	tests := []struct {
+		mode, escaped string
+		maxLen        int
+		want          string
+	}{
+		// Cut right after a complete escape
+		{ParseModeMarkdown, `\_\_\_`, 5, `\_...`},
+		// Cut between a backslash and the character it escapes
+		{ParseModeMarkdown, `\_\_\_x`, 6, `\_...`},
+		{ParseModeMarkdown, `a\*bcd`, 5, `a...`},
+		// An escaped backslash is a complete pair
+		{ParseModeMarkdown, `\\\\xy`, 5, `\\...`},
+		{ParseModeHTML, "&amp;&amp;", 8, "&amp;..."},
+		{ParseModeHTML, "&amp;&amp;", 7, "..."},
+		{ParseModeHTML, "a&lt;b&gt;", 9, "a&lt;b..."},
+		{ParseModeHTML, "a&lt;b&gt;", 8, "a&lt;..."},
+		// Nothing to cut
+		{ParseModeMarkdown, `\_x`, 3, `\_x`},
+	}
+
+	for _, tt := range tests {
+		withParseMode(t, tt.mode)
+		if got := truncateEscaped(tt.escaped, tt.maxLen); got != tt.want {
+			t.Errorf("%s: truncateEscaped(%q, %d) = %q, want %q", tt.mode, tt.escaped, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateEscapedEveryCut(t *testing.T) {
+	titles := map[string]string{
+		ParseModeMarkdown: strings.Repeat("_*[]`()~", 10),
+		ParseModeHTML:     strings.Repeat("<&>\"'", 10),
+	}
+
+	for mode, title := range titles {
+		withParseMode(t, mode)
+		escaped := escapeText(title)
+		for maxLen := 4; maxLen < utf8.RuneCountInString(escaped); maxLen++ {
+			got := truncateEscaped(escaped, maxLen)
+			if n := utf8.RuneCountInString(got); n > maxLen {
+				t.Errorf("%s: cut to %d gave %d characters", mode, maxLen, n)
+			}
+			body, ok := strings.CutSuffix(got, "...")
+			if !ok || !strings.HasPrefix(escaped, body) {
+				t.Errorf("%s: cut to %d gave %q, not a prefix of the escaped title", mode, maxLen, got)
+			}
+			if danglingEscape(mode, body) {
+				t.Errorf("%s: cut to %d split an escape sequence: %q", mode, maxLen, got)
+			}
+		}
+	}
+}
+
+func TestBuildCaptionEscapedTitles(t *testing.T) {
+	titles := map[string]string{
+		ParseModeMarkdown: strings.Repeat("_*[]`()~ ", 200),
+		ParseModeHTML:     strings.Repeat("<&> ", 400),
+	}
+	render := func(title string) string {
+		return "📹 " + bold("YouTube") + " - " + title + "\n▫️ Size: 1.0 MB"
+	}
+
+	for mode, title := range titles {
+		withParseMode(t, mode)
+		for _, limit := range []int{MaxCaptionLength, MaxCaptionLength - 1, MaxCaptionLength - 2, 100, 101} {
+			caption := fitTitle(limit, title, render)
+			if n := utf8.RuneCountInString(caption); n > limit {
+				t.Errorf("%s: caption is %d characters, over the %d limit", mode, n, limit)
+			}
+			escaped, ok := strings.CutSuffix(caption, "\n▫️ Size: 1.0 MB")
+			_, escaped, _ = strings.Cut(escaped, " - ")
+			if !ok || danglingEscape(mode, strings.TrimSuffix(escaped, "...")) {
+				t.Errorf("%s: title split an escape sequence at limit %d: %q", mode, limit, caption)
+			}
+		}
+	}
+}
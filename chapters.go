@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Chapter is one entry of the "chapters" list in yt-dlp's JSON output
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+// hasChapters reports whether a video is worth splitting by chapters
+func hasChapters(meta *VideoMetadata) bool {
+	return meta != nil && len(meta.Chapters) > 1
+}
+
+// withEmbeddedChapters switches an MP3 extraction to M4A with the chapters kept
+// as markers inside the single file, the non-split alternative for lectures and
+// podcasts
+func withEmbeddedChapters(args []string) []string {
+	result := make([]string, 0, len(args)+1)
+	for i := 0; i < len(args); i++ {
+		result = append(result, args[i])
+		if args[i] == "--audio-format" && i+1 < len(args) {
+			result = append(result, "m4a")
+			i++
+		}
+	}
+	return append(result, "--embed-chapters")
+}
+
+// handleChapterAudioDownload extracts the audio and sends one file per chapter.
+// Videos without chapters fall back to the regular single-file extraction.
+func handleChapterAudioDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, statusMsgID int) {
+	if !requireFFmpeg(bot, chatID) {
+		return
+	}
+	if !hasChapters(info.Meta) {
+		handleAudioDownload(bot, runner, chatID, info, "mp3", statusMsgID)
+		return
+	}
+
+	// Create unique filenames with instance ID and timestamp
+	timestamp := time.Now().UnixNano()
+	prefix := filePrefix("audio", timestamp)
+	audioOutput := prefix + ".%(ext)s"
+
+	ytdlpArgs := buildAudioArgs(info, audioOutput)
+	ytdlpArgs = append(ytdlpArgs,
+		"--split-chapters",
+		"-o", "chapter:"+prefix+"_chapter_%(section_number)03d.%(ext)s",
+	)
+
+	// Run yt-dlp, trying alternate YouTube player clients when needed
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, "MP3 chapters")
+
+	// The full-length file and any chapters are cleaned up whatever happens
+	defer func() {
+		leftovers, _ := filepath.Glob(prefix + "*")
+		for _, file := range leftovers {
+			os.Remove(file)
+		}
+	}()
+
+	if err != nil {
+		reportDownloadFailure(bot, chatID, info, "chapter extraction", err, output)
+		return
+	}
+
+	chapterFiles, _ := filepath.Glob(prefix + "_chapter_*")
+	sort.Strings(chapterFiles)
+	if len(chapterFiles) == 0 {
+		sendFailure(bot, chatID, "❌ No chapter files found after extraction completed.")
+		return
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(
+		chatID,
+		statusMsgID,
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("✅ "+bold("Audio Extraction Complete!")+"\n\n%s\n\nUploading %d chapters to Telegram...", title, len(chapterFiles))
+		}),
+	)
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+
+	failed := false
+	for i, chapterFile := range chapterFiles {
+		chapterTitle := fmt.Sprintf("Chapter %d", i+1)
+		if i < len(info.Meta.Chapters) && info.Meta.Chapters[i].Title != "" {
+			chapterTitle = info.Meta.Chapters[i].Title
+		}
+
+		if err := runPostDownloadHook(runner, chapterFile); err != nil {
+			jobLog(info).Printf("Chapter %d: %v", i+1, err)
+			failed = true
+			continue
+		}
+
+		fileInfo, err := os.Stat(chapterFile)
+		if err != nil {
+			jobLog(info).Println("Failed to get chapter file info:", err)
+			failed = true
+			continue
+		}
+		fileSizeMB := float64(fileInfo.Size()) / 1048576
+
+		// Each chapter is checked against the limit on its own
+		if fileInfo.Size() > MaxFileSize {
+			bot.Send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("⚠️ Chapter \"%s\" (%.1f MB) exceeds Telegram's limit and was skipped.", chapterTitle, fileSizeMB)))
+			continue
+		}
+
+		caption := buildCaption(info.Title, func(title string) string {
+			return fmt.Sprintf("🎵 "+bold("%s")+" - %s\n▫️ Chapter %d/%d: %s\n▫️ Format: MP3\n▫️ Size: %.1f MB",
+				info.Platform, title, i+1, len(chapterFiles), escapeText(chapterTitle), fileSizeMB)
+		})
+
+		file, err := os.Open(chapterFile)
+		if err != nil {
+			jobLog(info).Println("Failed to open chapter file:", err)
+			failed = true
+			continue
+		}
+
+		// Name the uploaded file after the chapter
+		audio := tgbotapi.NewAudio(chatID, tgbotapi.FileReader{
+			Name:   fmt.Sprintf("%02d - %s%s", i+1, chapterTitle, filepath.Ext(chapterFile)),
+			Reader: file,
+		})
+		audio.Caption = caption
+		audio.ParseMode = parseMode
+		audio.Title = chapterTitle
+		_, err = sendWithRetry(bot, audio, file)
+		file.Close()
+		if err != nil {
+			jobLog(info).Println("Failed to send chapter:", err)
+			failed = true
+		}
+	}
+
+	if failed {
+		sendFailure(bot, chatID, "❌ Some chapters could not be sent.")
+		return
+	}
+	downloadSucceeded(chatID)
+	sendExtras(bot, chatID, info, infoJSONFile(prefix))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/exec"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const checkUsage = "✍️ Usage: /check <url>\n\nTells you whether the bot can download the link, without downloading it."
+
+// handleCheck is a pre-flight for /check <url>: it asks yt-dlp to simulate the
+// download, which resolves the video without fetching it, and reports whether
+// it would work. It shares the metadata slots and timeout, so checks never
+// hold up downloads.
+func handleCheck(bot *tgbotapi.BotAPI, runner Runner, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 1 {
+		bot.Send(tgbotapi.NewMessage(chatID, checkUsage))
+		return
+	}
+
+	statusMsg, err := sendWithRetry(bot, tgbotapi.NewMessage(chatID, "🔍 Checking the link..."))
+	if err != nil {
+		log.Println("Failed to send status message:", err)
+		return
+	}
+	reply := func(text string) {
+		sendWithRetry(bot, tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, text))
+	}
+
+	url, problem := checkLink(args[0])
+	if problem != "" {
+		reply("❌ Not supported: " + problem)
+		return
+	}
+
+	metadataSlots <- struct{}{}
+	defer func() { <-metadataSlots }()
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+
+	output, err := runner.Run(ctx, "yt-dlp",
+		withExtraArgs([]string{"--simulate", "--no-playlist", "--print", "title"}, url)...)
+	if err != nil {
+		reply("❌ Not supported or unavailable: " + checkFailureReason(ctx, err))
+		return
+	}
+
+	title := strings.TrimSpace(string(output))
+	if title == "" {
+		title = "(untitled)"
+	}
+	reply("✅ Supported: " + title)
+}
+
+// checkFailureReason explains a failed check in the user's terms
+func checkFailureReason(ctx context.Context, err error) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return "the site took too long to respond."
+	}
+	var stderr string
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stderr = string(exitErr.Stderr)
+	}
+	if failure, _ := classifyFailure(err, stderr); failure.Kind == UserFailure {
+		return failure.Message
+	}
+	if line := lastLines(stderr, 1); line != "" {
+		return strings.TrimPrefix(line, "ERROR: ")
+	}
+	return "yt-dlp couldn't read this link."
+}
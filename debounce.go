@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultLinkDebounceSeconds is how soon after a link the same chat may send
+// another when LINK_DEBOUNCE_SECONDS is unset. Setting it to 0 turns this off.
+const DefaultLinkDebounceSeconds = 3
+
+// linkDebouncer keeps a chat that pastes many links at once from setting off a
+// metadata fetch and a keyboard for each. It is separate from the daily quota,
+// which counts downloads, not links.
+type linkDebouncer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[int64]time.Time
+}
+
+// linkDebounce is configured from LINK_DEBOUNCE_SECONDS at startup
+var linkDebounce = &linkDebouncer{interval: loadLinkDebounce(), last: make(map[int64]time.Time)}
+
+func loadLinkDebounce() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("LINK_DEBOUNCE_SECONDS"))
+	if err != nil || seconds < 0 {
+		seconds = DefaultLinkDebounceSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// allow reports whether the chat's link should be handled, recording it if so.
+// Ignored links don't restart the wait.
+func (d *linkDebouncer) allow(chatID int64) bool {
+	if d.interval <= 0 {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := d.last[chatID]; ok && now.Sub(last) < d.interval {
+		return false
+	}
+
+	// Forget chats that have been quiet, so the map doesn't grow forever
+	for id, last := range d.last {
+		if now.Sub(last) >= d.interval {
+			delete(d.last, id)
+		}
+	}
+	d.last[chatID] = now
+	return true
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// blankLinesPattern matches runs of blank lines, which descriptions are full of
+var blankLinesPattern = regexp.MustCompile(`\n\s*\n\s*\n+`)
+
+// cleanDescription trims trailing spaces and collapses runs of blank lines
+func cleanDescription(description string) string {
+	lines := strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// createDescriptionRow is the on/off switch for sending the video description
+func createDescriptionRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if info.Meta == nil || strings.TrimSpace(info.Meta.Description) == "" {
+		return nil
+	}
+	label := "📝 Include description: off"
+	if info.WithDescription {
+		label = "📝 Include description: on ✅"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "description:toggle"))
+}
+
+// sendDescription sends the video's description as a separate plain-text
+// message, cut to fit Telegram's message limit
+func sendDescription(bot *tgbotapi.BotAPI, chatID int64, info Download) {
+	if !info.WithDescription || info.Meta == nil {
+		return
+	}
+	description := cleanDescription(info.Meta.Description)
+	if description == "" {
+		return
+	}
+	sendWithRetry(bot, tgbotapi.NewMessage(chatID, truncateString("📝 Description\n\n"+description, MaxMessageLength)))
+}
+
+// sendExtras sends the optional extras the user asked for after the media itself
+func sendExtras(bot *tgbotapi.BotAPI, chatID int64, info Download, infoJSONPath string) {
+	sendDescription(bot, chatID, info)
+	sendInfoJSON(bot, chatID, info, infoJSONPath)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DirectPlatform is the platform name for plain links to a media file
+const DirectPlatform = "Direct link"
+
+// MaxRememberedDirectURLs bounds the set of links found to be media by their
+// content type; it is simply emptied when full
+const MaxRememberedDirectURLs = 1000
+
+// mediaExtensions mark a link as pointing straight at a media file. yt-dlp's
+// generic extractor downloads all of them, HLS playlists included.
+var mediaExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".webm": true, ".mkv": true,
+	".mp3": true, ".m4a": true, ".ogg": true, ".opus": true, ".wav": true, ".flac": true,
+	".m3u8": true,
+}
+
+var (
+	directURLsMu sync.Mutex
+	// directURLs holds links without a media extension whose HEAD response
+	// said they are media, so detectPlatform can recognize them afterwards
+	directURLs = make(map[string]bool)
+)
+
+// hasMediaExtension reports whether the link's path ends in a media file extension
+func hasMediaExtension(rawURL string) bool {
+	parsed, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return mediaExtensions[strings.ToLower(path.Ext(parsed.Path))]
+}
+
+// isDirectMediaURL reports whether the link is known to point at a media file
+func isDirectMediaURL(rawURL string) bool {
+	if hasMediaExtension(rawURL) {
+		return true
+	}
+	directURLsMu.Lock()
+	defer directURLsMu.Unlock()
+	return directURLs[rawURL]
+}
+
+// errPrivateAddress refuses connections to the bot's own machine or network
+var errPrivateAddress = errors.New("refusing to connect to a private address")
+
+// isPublicIP reports whether an address is outside the bot's own machine and network
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// isPublicHost refuses links to the bot's own machine or network, which
+// downloading on a user's behalf must never reach. It only checks what the
+// name resolves to now; publicDialer checks the address actually connected to.
+func isPublicHost(rawURL string) bool {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return false
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialer only connects to public addresses. It checks the address being
+// dialed, after DNS resolution, so a name that resolves differently by the time
+// of the request can't reach inside the network either.
+var publicDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+			return errPrivateAddress
+		}
+		return nil
+	},
+}
+
+// publicTransport makes every connection through publicDialer
+var publicTransport = &http.Transport{DialContext: publicDialer.DialContext}
+
+// checkPublicRedirect is a CheckRedirect for clients that fetch user-supplied
+// links: it bounds the number of hops and checks each one's host again
+func checkPublicRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MaxShortURLRedirects {
+		return errors.New("too many redirects")
+	}
+	if !isPublicHost(req.URL.String()) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// directMediaClient probes direct links, checking every redirect hop again
+var directMediaClient = &http.Client{
+	Timeout:       ShortURLTimeout,
+	Transport:     publicTransport,
+	CheckRedirect: checkPublicRedirect,
+}
+
+// probeDirectMedia asks the server what a link without a media extension
+// serves, remembering it when the content type is audio, video or HLS
+func probeDirectMedia(rawURL string) bool {
+	resp, err := directMediaClient.Head(rawURL)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "video/") && !strings.HasPrefix(contentType, "audio/") &&
+		!strings.Contains(contentType, "mpegurl") {
+		return false
+	}
+
+	directURLsMu.Lock()
+	defer directURLsMu.Unlock()
+	if len(directURLs) >= MaxRememberedDirectURLs {
+		directURLs = make(map[string]bool)
+	}
+	directURLs[rawURL] = true
+	return true
+}
+
+// directProxyURL is the proxy yt-dlp fetches direct links through, empty until
+// startDirectProxy has run. Direct links are refused without it.
+var directProxyURL string
+
+// startDirectProxy runs a local HTTP proxy for yt-dlp's direct link requests.
+// yt-dlp follows redirects and resolves names on its own, so its connections
+// go through publicDialer as well.
+func startDirectProxy() error {
+	if !isPlatformEnabled(DirectPlatform) {
+		return nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start the direct link proxy: %w", err)
+	}
+	directProxyURL = "http://" + listener.Addr().String()
+
+	go func() {
+		// Direct downloads fail from here on, which is the safe way to fail
+		log.Println("Direct link proxy stopped:", http.Serve(listener, directProxy{}))
+	}()
+	return nil
+}
+
+// directProxyArgs sends yt-dlp's requests for a direct link through the proxy.
+// It comes last among the options, so an operator --proxy can't override it.
+func directProxyArgs(url string) []string {
+	if detectPlatform(url) != DirectPlatform || directProxyURL == "" {
+		return nil
+	}
+	return []string{"--proxy", directProxyURL}
+}
+
+// directProxy tunnels HTTPS with CONNECT and forwards plain HTTP requests,
+// connecting to public addresses only
+type directProxy struct{}
+
+func (directProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		// A redirect goes back to yt-dlp, whose next request comes through here too
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Connection")
+		resp, err := publicTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for name, values := range resp.Header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	upstream, err := publicDialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	go func() {
+		io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+// countingServer serves a media response and counts the requests that reach it
+func countingServer(t *testing.T, hits *atomic.Int32, newServer func(http.Handler) *httptest.Server) *httptest.Server {
+	t.Helper()
+	server := newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "video/mp4")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbeDirectMediaRefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	internal := countingServer(t, &hits, httptest.NewServer)
+
+	if probeDirectMedia(internal.URL + "/clip") {
+		t.Error("a media server on a private address was accepted")
+	}
+	if hits.Load() != 0 {
+		t.Error("the probe reached a private address")
+	}
+}
+
+func TestDirectProxyRefusesPrivateAddresses(t *testing.T) {
+	proxy := httptest.NewServer(directProxy{})
+	defer proxy.Close()
+	proxyURL, _ := neturl.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	var hits atomic.Int32
+	plain := countingServer(t, &hits, httptest.NewServer)
+	if resp, err := client.Get(plain.URL + "/clip.mp4"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("plain HTTP to a private address got status %d", resp.StatusCode)
+		}
+	}
+
+	tunneled := countingServer(t, &hits, httptest.NewTLSServer)
+	if resp, err := client.Get(tunneled.URL + "/clip.mp4"); err == nil {
+		resp.Body.Close()
+		t.Errorf("HTTPS to a private address was tunneled, status %d", resp.StatusCode)
+	}
+
+	if hits.Load() != 0 {
+		t.Errorf("the proxy let %d requests through to a private address", hits.Load())
+	}
+}
+
+func TestDirectProxyArgs(t *testing.T) {
+	previous := directProxyURL
+	directProxyURL = "http://127.0.0.1:12345"
+	t.Cleanup(func() { directProxyURL = previous })
+
+	direct := withExtraArgs([]string{"-f", "best"}, "https://cdn.example.com/clip.mp4")
+	if got, _ := flagValue(direct, "--proxy"); got != directProxyURL {
+		t.Errorf("direct link args %q don't go through the proxy", direct)
+	}
+	if direct[len(direct)-1] != "https://cdn.example.com/clip.mp4" {
+		t.Errorf("URL isn't the last argument: %q", direct)
+	}
+
+	if platform := withExtraArgs(nil, "https://www.youtube.com/watch?v=abc"); slices.Contains(platform, "--proxy") {
+		t.Errorf("platform link args %q shouldn't use the direct link proxy", platform)
+	}
+}
+
+func TestDirectLinksToggle(t *testing.T) {
+	tests := []struct {
+		value     string
+		direct    bool
+		platforms []string
+	}{
+		{"", true, supportedPlatforms},
+		{"YouTube,TikTok", false, []string{"YouTube", "TikTok"}},
+		{"youtube, Direct", true, []string{"YouTube"}},
+		{"direct", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("ENABLED_PLATFORMS", tt.value)
+		if got := loadDirectLinksEnabled(); got != tt.direct {
+			t.Errorf("ENABLED_PLATFORMS=%q: direct links enabled = %v, want %v", tt.value, got, tt.direct)
+		}
+		if got := loadEnabledPlatforms(); !slices.Equal(got, tt.platforms) {
+			t.Errorf("ENABLED_PLATFORMS=%q: platforms = %q, want %q", tt.value, got, tt.platforms)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxAutoDowngrades is how many times a too-large video is retried at a lower quality
+const MaxAutoDowngrades = 2
+
+// autoDowngrade is on unless AUTO_DOWNGRADE is set to "false", "0" or "off"
+var autoDowngrade = loadAutoDowngrade()
+
+func loadAutoDowngrade() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("AUTO_DOWNGRADE"))) {
+	case "false", "0", "off", "no":
+		return false
+	}
+	return true
+}
+
+// fallbackHeights is the quality ladder used when the video's formats are unknown
+var fallbackHeights = []int{360, 480, 720, 1080}
+
+// lowerQuality returns the next quality below the one that came out too large,
+// or false when there is nothing lower to try
+func lowerQuality(info Download, quality string) (string, bool) {
+	heights := availableHeights(info.Meta)
+	if len(heights) == 0 {
+		heights = fallbackHeights
+	}
+
+	var limit int
+	switch {
+	case quality == "best" || quality == "original" || quality == "fit":
+		// Whatever "best" picked was probably the top resolution
+		limit = heights[len(heights)-1]
+	default:
+		height, ok := parseHeight(quality)
+		if !ok {
+			return "", false
+		}
+		limit = height
+	}
+
+	for i := len(heights) - 1; i >= 0; i-- {
+		if heights[i] < limit {
+			return fmt.Sprintf("%dp", heights[i]), true
+		}
+	}
+	return "", false
+}
+
+// retryLowerQuality re-runs a video download that came out over Telegram's limit
+// at the next lower quality, up to MaxAutoDowngrades times. It reports whether it
+// took over the download; path is the oversized file, which is removed first.
+func retryLowerQuality(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, quality, path string, statusMsgID int) bool {
+	if !autoDowngrade || info.Downgrades >= MaxAutoDowngrades || info.FormatSelector != "" {
+		return false
+	}
+	lower, ok := lowerQuality(info, quality)
+	if !ok {
+		return false
+	}
+
+	os.Remove(path)
+	jobLog(info).Printf("%s exceeded the size limit, retrying at %s", quality, lower)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ %s exceeded the limit, retrying at %s.", quality, lower)))
+
+	info.Downgrades++
+	handleVideoDownload(bot, runner, chatID, info, lower, statusMsgID)
+	return true
+}
+
+// handleRejectedAsTooBig deals with Telegram refusing an upload that passed our
+// own size check: videos are retried at a lower quality, anything else goes
+// through the storage channel or a download link, and failing those the user is
+// told what happened rather than getting the generic send failure.
+func handleRejectedAsTooBig(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, format, quality, path string, sizeMB float64, statusMsgID int) {
+	jobLog(info).Printf("Telegram rejected the %.1f MB %s upload as too big", sizeMB, format)
+	if format == "video" && retryLowerQuality(bot, runner, chatID, info, quality, path, statusMsgID) {
+		return
+	}
+	if sendOversized(bot, chatID, info, path, sizeMB) {
+		return
+	}
+	message := fmt.Sprintf("⚠️ Telegram rejected the file (%.1f MB) as too big.", sizeMB)
+	if format == "video" {
+		message += " Try a lower quality option."
+	}
+	sendFailure(bot, chatID, message)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultExcerptMinutes are the "first N minutes" presets when EXCERPT_MINUTES is unset
+const DefaultExcerptMinutes = "1,5,10"
+
+// excerptPresets is read from EXCERPT_MINUTES, a comma-separated list of minutes
+var excerptPresets = loadExcerptPresets()
+
+func loadExcerptPresets() []int {
+	value := os.Getenv("EXCERPT_MINUTES")
+	if strings.TrimSpace(value) == "" {
+		value = DefaultExcerptMinutes
+	}
+
+	var presets []int
+	for _, field := range strings.Split(value, ",") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || minutes <= 0 {
+			log.Printf("Ignoring invalid value %q in EXCERPT_MINUTES", field)
+			continue
+		}
+		presets = append(presets, minutes)
+	}
+	sort.Ints(presets)
+	return presets
+}
+
+// excerptChoices returns the presets that are shorter than the video, so each
+// one actually saves something
+func excerptChoices(meta *VideoMetadata) []int {
+	if meta == nil || meta.Duration <= 0 {
+		return nil
+	}
+	var choices []int
+	for _, minutes := range excerptPresets {
+		if float64(minutes*60) < meta.Duration {
+			choices = append(choices, minutes)
+		}
+	}
+	return choices
+}
+
+// createExcerptRow opens the "first N minutes" picker for long enough videos
+func createExcerptRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if len(excerptChoices(info.Meta)) == 0 {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⏱ First minutes only", "first:pick"))
+}
+
+// createExcerptKeyboard offers one button per preset, and a way back
+func createExcerptKeyboard(info Download) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, minutes := range excerptChoices(info.Meta) {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("⏱ First %d min", minutes), fmt.Sprintf("first:%d", minutes)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", "menu:formats")),
+	)
+}
+
+// excerptArgs limits the download to the start of the video. yt-dlp fetches
+// only the needed part where the site allows, so long videos are quick to sample.
+func excerptArgs(info Download) []string {
+	if info.FirstMinutes <= 0 {
+		return nil
+	}
+	return []string{"--download-sections", fmt.Sprintf("*0-%d:00", info.FirstMinutes)}
+}
+
+// excerptNote tells the caption reader the video was cut short
+func excerptNote(info Download) string {
+	if info.FirstMinutes <= 0 {
+		return ""
+	}
+	if info.LiveFromStart {
+		return fmt.Sprintf("\n▫️ Live stream, first %d minutes aired", info.FirstMinutes)
+	}
+	return fmt.Sprintf("\n▫️ First %d minutes only", info.FirstMinutes)
+}
+
+// handleExcerptDownload downloads the first minutes of a video in the best quality
+func handleExcerptDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, minutes string, statusMsgID int) {
+	n, err := strconv.Atoi(minutes)
+	if err != nil || n <= 0 {
+		sendFailure(bot, chatID, "❌ Unknown excerpt length.")
+		return
+	}
+	info.FirstMinutes = n
+	handleVideoDownload(bot, runner, chatID, info, "best", statusMsgID)
+}
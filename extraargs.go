@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// extraArgs are operator-supplied yt-dlp options from YTDLP_EXTRA_ARGS.
+//
+// They are inserted after the bot's own options and immediately before the URL
+// on every yt-dlp invocation (metadata fetches and downloads), so they can
+// override the bot's defaults, e.g. YTDLP_EXTRA_ARGS='--limit-rate 5M --user-agent "Mozilla/5.0"'.
+var extraArgs []string
+
+// allowedExtraArgs are the options YTDLP_EXTRA_ARGS may use: networking,
+// throttling, request headers and format preferences. yt-dlp has many options
+// that run commands (--exec, --netrc-cmd, --use-postprocessor), read or write
+// arbitrary files (--batch-file, --load-info-json, --print-to-file) or load code
+// (--plugin-dirs), and it accepts abbreviations of long options, so only known
+// safe ones are let through.
+var allowedExtraArgs = map[string]bool{
+	// Network
+	"--proxy": true, "--socket-timeout": true, "--source-address": true,
+	"--force-ipv4": true, "-4": true, "--force-ipv6": true, "-6": true,
+	"--impersonate": true, "--no-check-certificates": true, "--no-check-certificate": true,
+	"--prefer-insecure": true, "--legacy-server-connect": true,
+	// Geo restriction
+	"--geo-verification-proxy": true, "--xff": true,
+	"--geo-bypass": true, "--no-geo-bypass": true, "--geo-bypass-country": true,
+	// Download speed and retries
+	"--limit-rate": true, "-r": true, "--throttled-rate": true,
+	"--retries": true, "-R": true, "--fragment-retries": true, "--retry-sleep": true,
+	"--extractor-retries": true, "--concurrent-fragments": true, "-N": true,
+	"--http-chunk-size": true, "--buffer-size": true,
+	"--sleep-requests": true, "--sleep-interval": true, "--min-sleep-interval": true,
+	"--max-sleep-interval": true, "--sleep-subtitles": true,
+	// Request headers
+	"--user-agent": true, "--referer": true, "--add-headers": true, "--add-header": true,
+	// Extraction and format preferences
+	"--extractor-args": true, "--format-sort": true, "-S": true,
+	"--format-sort-force": true, "--no-format-sort-force": true,
+	"--prefer-free-formats": true, "--no-prefer-free-formats": true,
+	"--check-formats": true, "--no-check-formats": true,
+	"--live-from-start": true, "--no-live-from-start": true,
+	"--hls-use-mpegts": true, "--no-hls-use-mpegts": true,
+	// Output that stays where the bot looks for it
+	"--verbose": true, "-v": true, "--no-warnings": true, "--ignore-config": true,
+	"--no-mtime": true, "--restrict-filenames": true, "--no-cache-dir": true,
+}
+
+// loadExtraArgs parses and validates YTDLP_EXTRA_ARGS
+func loadExtraArgs() error {
+	value := os.Getenv("YTDLP_EXTRA_ARGS")
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(value, ";|&$`<>\n\r") {
+		return fmt.Errorf("YTDLP_EXTRA_ARGS contains shell metacharacters")
+	}
+
+	args, err := splitArgs(value)
+	if err != nil {
+		return fmt.Errorf("YTDLP_EXTRA_ARGS: %w", err)
+	}
+
+	// Anything that isn't an option is taken to be the value of the one before it
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(arg, "=")
+		if !allowedExtraArgs[name] {
+			return fmt.Errorf("YTDLP_EXTRA_ARGS: option %s is not allowed", name)
+		}
+	}
+
+	extraArgs = args
+	return nil
+}
+
+// splitArgs tokenizes a command line the way a POSIX shell would for plain
+// words, single and double quotes, and backslash escapes, without expanding anything
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// withExtraArgs appends the geo bypass, cookie and operator options, the direct
+// link proxy and then the URL
+func withExtraArgs(args []string, url string) []string {
+	result := append([]string{}, args...)
+	result = append(result, geoArgs...)
+	result = append(result, cookieArgs(url)...)
+	result = append(result, extraArgs...)
+	result = append(result, directProxyArgs(url)...)
+	return append(result, url)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoadExtraArgs(t *testing.T) {
+	previous := extraArgs
+	t.Cleanup(func() { extraArgs = previous })
+
+	tests := []struct {
+		value string
+		want  []string // nil when the value must be refused
+	}{
+		{`--limit-rate 5M --user-agent "Mozilla/5.0 (X11)"`, []string{"--limit-rate", "5M", "--user-agent", "Mozilla/5.0 (X11)"}},
+		{"--socket-timeout=30 -4", []string{"--socket-timeout=30", "-4"}},
+		{`--add-header "Referer: https://example.com"`, []string{"--add-header", "Referer: https://example.com"}},
+		{"--extractor-args youtube:player_client=web", []string{"--extractor-args", "youtube:player_client=web"}},
+
+		// Options that run commands, touch arbitrary files or load code
+		{"--exec rm", nil},
+		{"--exec-before-download rm", nil},
+		{"--netrc-cmd cat", nil},
+		{"--use-postprocessor Exec:cmd=id", nil},
+		{"--print-to-file title /etc/motd", nil},
+		{"--load-info-json /tmp/x.json", nil},
+		{"--batch-file /etc/passwd", nil},
+		{"-a /etc/passwd", nil},
+		{"--config-locations /tmp/yt-dlp.conf", nil},
+		{"--config-location /tmp/yt-dlp.conf", nil},
+		{"--plugin-dirs /tmp/plugins", nil},
+		{"--downloader-args aria2c:--on-download-complete=id", nil},
+		{"--ffmpeg-location /tmp/ffmpeg", nil},
+		{"-o /tmp/%(title)s", nil},
+		{"--paths /tmp", nil},
+		// yt-dlp would take an abbreviation for the full option
+		{"--exe rm", nil},
+		{"--use-post=Exec:cmd=id", nil},
+		// Shell syntax is refused outright
+		{"--limit-rate 5M; rm -rf /", nil},
+	}
+
+	for _, tt := range tests {
+		extraArgs = nil
+		t.Setenv("YTDLP_EXTRA_ARGS", tt.value)
+		err := loadExtraArgs()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q was accepted", tt.value)
+			}
+			if extraArgs != nil {
+				t.Errorf("%q set extraArgs to %q", tt.value, extraArgs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.value, err)
+		} else if !slices.Equal(extraArgs, tt.want) {
+			t.Errorf("%q parsed as %q, want %q", tt.value, extraArgs, tt.want)
+		}
+	}
+}
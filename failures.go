@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FailureKind tells apart problems the user can fix from problems on our side
+type FailureKind int
+
+const (
+	UserFailure FailureKind = iota
+	ServerFailure
+)
+
+// Failure is a categorized download error with the message to show the user
+type Failure struct {
+	Kind    FailureKind
+	Message string
+}
+
+// userErrorPatterns map yt-dlp error text to messages about things the user can act on
+var userErrorPatterns = []struct {
+	patterns []string
+	message  string
+}{
+	// Geo blocks come first, since YouTube words them as "Video unavailable. ..."
+	{[]string{"not available in your country", "not made this video available in your country",
+		"not available from your location", "geo restrict", "geo-restrict", "blocked in your country"},
+		"🌍 This video isn't available in the server's region, so the bot can't download it."},
+	{[]string{"unsupported url", "no video formats found", "is not a valid url"},
+		"🔗 This link isn't supported. Please check that it points to a video."},
+	{[]string{"private video", "this video is private", "video is private", "private account"},
+		"🔒 This content is private and can't be downloaded."},
+	{[]string{"login required", "sign in to view", "requires authentication", "log in to"},
+		"🔑 This content requires a login and isn't available to the bot."},
+	{[]string{"password protected", "password-protected", "video-password"},
+		"🔑 This video is password-protected or only shared within a workspace, so the bot can't access it."},
+	{[]string{"video unavailable", "has been removed", "no longer available", "http error 404", "does not exist", "content isn't available"},
+		"🚫 This video is unavailable. It may have been deleted or made private."},
+	{[]string{"copyright", "blocked it on copyright grounds"},
+		"©️ This video is blocked for copyright reasons."},
+	{[]string{"premieres in", "this live event will begin", "is not currently live"},
+		"📅 This video hasn't been published yet. Try again once it's live."},
+}
+
+// serverErrorPatterns map yt-dlp error text to a short description for the logs
+var serverErrorPatterns = []struct {
+	patterns    []string
+	description string
+}{
+	{[]string{"no space left on device"}, "disk full"},
+	{[]string{"ffmpeg not found", "ffprobe and ffmpeg not found", "ffmpeg is not installed"}, "ffmpeg missing"},
+	{[]string{"postprocessing:", "conversion failed"}, "post-processing failed"},
+	{[]string{"traceback (most recent call last)"}, "yt-dlp crashed"},
+	{[]string{"http error 429", "too many requests"}, "rate limited by the platform"},
+}
+
+// classifyFailure decides whether a failed yt-dlp run was the user's or the server's problem
+func classifyFailure(err error, output string) (Failure, string) {
+	if errors.Is(err, errStartFailed) {
+		return Failure{ServerFailure, ""}, "yt-dlp could not be started"
+	}
+
+	lower := strings.ToLower(output)
+	if isAgeRestricted(output) {
+		return Failure{UserFailure, ageRestrictedHint}, ""
+	}
+	for _, p := range userErrorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				return Failure{UserFailure, p.message}, ""
+			}
+		}
+	}
+	for _, p := range serverErrorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				return Failure{ServerFailure, ""}, p.description
+			}
+		}
+	}
+	return Failure{ServerFailure, ""}, "unrecognized yt-dlp failure"
+}
+
+// lastLines returns the final n lines of command output, where yt-dlp puts its errors
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reportDownloadFailure tells the user what went wrong. User-fixable problems get a
+// specific explanation; server problems are logged with full context, forwarded to
+// the admins and answered with a generic message and a Retry button.
+func reportDownloadFailure(bot *tgbotapi.BotAPI, chatID int64, info Download, action string, err error, output string) {
+	failure, description := classifyFailure(err, output)
+
+	if needsInstagramLogin(info, output) {
+		jobLog(info).Printf("%s failed for chat %d (%s): Instagram login required", action, chatID, info.URL)
+		reportInstagramLogin(bot, chatID)
+		return
+	}
+
+	if failure.Kind == UserFailure {
+		jobLog(info).Printf("%s failed for chat %d (%s): user error: %v", action, chatID, info.URL, err)
+		stats.DownloadsFailed.Add(1)
+		bot.Send(tgbotapi.NewMessage(chatID, failure.Message))
+		return
+	}
+
+	details := lastLines(output, 5)
+	jobLog(info).Printf("%s failed for chat %d: server error (%s): platform=%s url=%s err=%v\n%s",
+		action, chatID, description, info.Platform, info.URL, err, details)
+
+	sendFailure(bot, chatID, "⚠️ A server error occurred while processing your request. The admins have been notified."+
+		supportReference(info))
+	notifyAdmins(bot, fmt.Sprintf("🛠 Server error during %s (%s)\n\nChat: %d\nJob: %s\nPlatform: %s\nURL: %s\nError: %v\n\n%s",
+		action, description, chatID, info.JobID, info.Platform, info.URL, err, details))
+}
+
+// reportInstagramLogin tells the user that Instagram wants a login. If a session
+// is configured it has probably expired, which the admins need to know about.
+func reportInstagramLogin(bot *tgbotapi.BotAPI, chatID int64) {
+	stats.DownloadsFailed.Add(1)
+	bot.Send(tgbotapi.NewMessage(chatID, instagramLoginMessage))
+	if instagramCookieFile != "" {
+		notifyAdmins(bot, "🔑 Instagram refused a download despite INSTAGRAM_SESSIONID being set. The session may have expired.")
+	}
+}
+
+// notifyAdmins sends an operational message to every configured admin
+func notifyAdmins(bot *tgbotapi.BotAPI, text string) {
+	for _, adminID := range adminIDs {
+		if _, err := bot.Send(tgbotapi.NewMessage(adminID, truncateString(text, MaxMessageLength))); err != nil {
+			log.Printf("Failed to notify admin %d: %v", adminID, err)
+		}
+	}
+}
+
+// isFormatUnavailable reports whether yt-dlp rejected the selected format
+func isFormatUnavailable(output string) bool {
+	return strings.Contains(strings.ToLower(output), "requested format is not available")
+}
+
+// offerAvailableFormats turns the status message back into a format keyboard,
+// built from the formats the video really has, so the user can pick again
+func offerAvailableFormats(bot *tgbotapi.BotAPI, runner Runner, chatID int64, statusMsgID int, info Download) {
+	meta := info.Meta
+	if meta == nil {
+		meta = getVideoInfo(runner, info.URL)
+	}
+
+	heights := availableHeights(meta)
+	if len(heights) == 0 {
+		sendFailure(bot, chatID, "❌ The selected format isn't available for this video, and no alternatives were found.")
+		return
+	}
+
+	// The buttons look the download up by the status message, which /retry and
+	// /dl create without a cache entry
+	info.Meta = meta
+	urlCache.set(getCacheKey(chatID, statusMsgID), info)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+		chatID,
+		statusMsgID,
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("⚠️ "+bold("That format isn't available")+"\n\n%s\n\nPlease choose one of the formats this video actually has:", title)
+		}),
+		createResolutionKeyboard(meta, heights),
+	)
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+}
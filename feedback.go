@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FeedbackCooldown is the minimum time between two feedback messages from one user
+const FeedbackCooldown = 5 * time.Minute
+
+// adminIDs holds the chat IDs configured in ADMIN_IDS
+var adminIDs []int64
+
+// feedbackTimes tracks when each user last sent feedback
+var (
+	feedbackMu    sync.Mutex
+	feedbackTimes = make(map[int64]time.Time)
+)
+
+// loadAdminIDs parses the comma-separated ADMIN_IDS environment variable
+func loadAdminIDs() {
+	for _, field := range strings.Split(os.Getenv("ADMIN_IDS"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring invalid admin ID %q: %v", field, err)
+			continue
+		}
+		adminIDs = append(adminIDs, id)
+	}
+}
+
+func isAdmin(userID int64) bool {
+	for _, id := range adminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// allowFeedback reports whether the user is outside the feedback cooldown and,
+// if so, starts a new cooldown window
+func allowFeedback(userID int64) (bool, time.Duration) {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+
+	if last, ok := feedbackTimes[userID]; ok {
+		if wait := FeedbackCooldown - time.Since(last); wait > 0 {
+			return false, wait
+		}
+	}
+	feedbackTimes[userID] = time.Now()
+	return true, 0
+}
+
+func handleFeedback(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	text := strings.TrimSpace(message.CommandArguments())
+	if text == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "✍️ Usage: /feedback <your message>\n\nFor example: /feedback this TikTok link fails to download"))
+		return
+	}
+
+	if len(adminIDs) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Feedback is not available right now."))
+		return
+	}
+
+	user := message.From
+	if user == nil {
+		return
+	}
+
+	if ok, wait := allowFeedback(user.ID); !ok {
+		bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("⏳ You've sent feedback recently. Please try again in %d minute(s).", int(wait.Minutes())+1)))
+		return
+	}
+
+	sender := fmt.Sprintf("ID %d", user.ID)
+	if user.UserName != "" {
+		sender = fmt.Sprintf("@%s (ID %d)", user.UserName, user.ID)
+	}
+	report := fmt.Sprintf("📨 Feedback from %s\n\n%s", sender, text)
+
+	for _, adminID := range adminIDs {
+		if _, err := bot.Send(tgbotapi.NewMessage(adminID, report)); err != nil {
+			log.Printf("Failed to forward feedback to admin %d: %v", adminID, err)
+		}
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "✅ Thanks! Your feedback was sent to the admins."))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ffmpegRequiredMessage is shown instead of running an audio extraction that
+// can't work without ffmpeg
+const ffmpegRequiredMessage = "🎵 Audio extraction needs ffmpeg, which isn't available on this server.\n\n" +
+	"Video downloads still work, in the formats that come as a single file."
+
+// ffmpegMissing is set when ffmpeg or ffprobe couldn't be found, at startup or
+// because a download failed for lack of them. yt-dlp's post-processing needs
+// both.
+var ffmpegMissing atomic.Bool
+
+// ffprobeMissing is set when ffprobe in particular couldn't be found, so the
+// bot can't inspect downloaded files itself
+var ffprobeMissing atomic.Bool
+
+// checkFFmpeg looks for ffmpeg and ffprobe at startup. Without them the bot
+// keeps running, limited to single-file video downloads.
+func checkFFmpeg() {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			log.Printf("Warning: %s not found in PATH. Audio extraction is disabled and videos are limited to single-file formats.", tool)
+			ffmpegMissing.Store(true)
+			if tool == "ffprobe" {
+				ffprobeMissing.Store(true)
+			}
+		}
+	}
+}
+
+// hasFFmpeg reports whether ffmpeg is believed to be usable
+func hasFFmpeg() bool {
+	return !ffmpegMissing.Load()
+}
+
+// hasFFprobe reports whether downloaded files can be inspected with ffprobe
+func hasFFprobe() bool {
+	return !ffprobeMissing.Load()
+}
+
+// isFFmpegMissing reports whether yt-dlp failed because it couldn't find ffmpeg
+func isFFmpegMissing(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "ffmpeg not found") ||
+		strings.Contains(lower, "ffprobe and ffmpeg not found") ||
+		strings.Contains(lower, "ffmpeg is not installed")
+}
+
+// markFFmpegMissing records that ffmpeg went missing after startup, telling the
+// admins the first time it happens
+func markFFmpegMissing(bot *tgbotapi.BotAPI, info Download) {
+	if ffmpegMissing.CompareAndSwap(false, true) {
+		jobLog(info).Println("ffmpeg is missing, falling back to single-file formats")
+		notifyAdmins(bot, "🛠 yt-dlp couldn't find ffmpeg. Audio extraction is disabled and videos are limited to single-file formats until the bot is restarted with ffmpeg installed.")
+	}
+}
+
+// requireFFmpeg tells the user when an audio download can't run, reporting
+// whether it can go ahead
+func requireFFmpeg(bot *tgbotapi.BotAPI, chatID int64) bool {
+	if hasFFmpeg() {
+		return true
+	}
+	stats.DownloadsFailed.Add(1)
+	bot.Send(tgbotapi.NewMessage(chatID, ffmpegRequiredMessage))
+	return false
+}
+
+// singleFileFormatCode picks a format with video and audio in one file, which
+// is all yt-dlp can download without ffmpeg to merge streams
+func singleFileFormatCode(quality string) string {
+	if progressive, ok := progressiveFormatCode(quality); ok {
+		return progressive
+	}
+	if quality == "smallest" {
+		return "worst[ext=mp4]/worst"
+	}
+	return "best[ext=mp4]/best"
+}
+
+// singleFileSelector rewrites a format selector for downloads without ffmpeg.
+// Alternatives that merge a video and an audio stream become the single-file
+// pick with the same filters, so a height limit, an audio language or an
+// excluded format still apply; alternatives naming a fixed video-only format
+// are dropped. Single-file alternatives are kept as they are. fallback is used
+// when nothing is left.
+func singleFileSelector(formatCode, fallback string) string {
+	var alternatives []string
+	for _, alternative := range strings.Split(formatCode, "/") {
+		if video, audio, merged := strings.Cut(alternative, "+"); merged {
+			name, filters, _ := strings.Cut(video, "[")
+			if filters != "" {
+				filters = "[" + filters
+			}
+			if _, audioFilters, ok := strings.Cut(audio, "["); ok {
+				filters += "[" + audioFilters
+			}
+			switch strings.TrimSuffix(name, "*") {
+			case "bestvideo", "bv":
+				alternative = "best" + filters
+			case "worstvideo", "wv":
+				alternative = "worst" + filters
+			default:
+				continue
+			}
+		}
+		if !slices.Contains(alternatives, alternative) {
+			alternatives = append(alternatives, alternative)
+		}
+	}
+	if len(alternatives) == 0 {
+		return fallback
+	}
+	return strings.Join(alternatives, "/")
+}
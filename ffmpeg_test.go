@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// withoutFFmpeg makes the rest of the test run as if ffmpeg and ffprobe were
+// not installed
+func withoutFFmpeg(t *testing.T) {
+	t.Helper()
+	ffmpegMissing.Store(true)
+	ffprobeMissing.Store(true)
+	t.Cleanup(func() {
+		ffmpegMissing.Store(false)
+		ffprobeMissing.Store(false)
+	})
+}
+
+func TestSingleFileSelector(t *testing.T) {
+	const fallback = "best[ext=mp4]/best"
+	tests := []struct {
+		formatCode, want string
+	}{
+		{"best", "best"},
+		{"22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]", "22/best[height<=720]"},
+		{"bestvideo+bestaudio[language=de]/best", "best[language=de]/best"},
+		{"bv*[height<=1080]+ba/b", "best[height<=1080]/b"},
+		{"worst[ext=mp4]/worst/wv*+wa", "worst[ext=mp4]/worst"},
+		{"137+140", fallback},
+	}
+
+	for _, tt := range tests {
+		if got := singleFileSelector(tt.formatCode, fallback); got != tt.want {
+			t.Errorf("singleFileSelector(%q) = %q, want %q", tt.formatCode, got, tt.want)
+		}
+	}
+}
+
+func TestVideoArgsWithoutFFmpeg(t *testing.T) {
+	withoutFFmpeg(t)
+	tests := []struct {
+		name    string
+		info    Download
+		quality string
+		want    string
+	}{
+		{
+			name:    "explicit selector",
+			info:    Download{Platform: "Vimeo", URL: "https://vimeo.com/1", FormatSelector: "bv*[height<=1080]+ba/b"},
+			quality: "best",
+			want:    "best[height<=1080]/b",
+		},
+		{
+			name:    "audio language",
+			info:    Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc", AudioLanguage: "de"},
+			quality: "720p",
+			want:    "best[height<=720][language=de]/22/best[height<=720]",
+		},
+		{
+			name:    "TikTok without watermark",
+			info:    Download{Platform: "TikTok", URL: "https://www.tiktok.com/@user/video/1"},
+			quality: "nowm",
+			want:    resolveFormatCode("TikTok", "nowm"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := buildVideoArgs(tt.info, tt.quality, "video.%(ext)s")
+			if got, _ := flagValue(args, "-f"); got != tt.want {
+				t.Errorf("-f = %q, want %q", got, tt.want)
+			}
+			if _, remux := flagValue(args, "--remux-video"); remux {
+				t.Errorf("remuxing needs ffmpeg: %q", args)
+			}
+		})
+	}
+}
+
+// Without ffprobe the file can't be checked, but it is still sent as a video
+func TestVideoSentWithoutFFprobe(t *testing.T) {
+	withoutFFmpeg(t)
+
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`)
+	}))
+	t.Cleanup(server.Close)
+	bot := &tgbotapi.BotAPI{Token: "test", Client: server.Client(), Buffer: 100}
+	bot.SetAPIEndpoint(server.URL + "/bot%s/%s")
+
+	runner := &fakeRunner{respond: func(call fakeCall) (string, error) {
+		if call.name == "yt-dlp" {
+			output, _ := flagValue(call.args, "-o")
+			path := strings.Replace(output, "%(ext)s", "mp4", 1)
+			t.Cleanup(func() { os.Remove(path) })
+			return "", os.WriteFile(path, []byte("video"), 0o644)
+		}
+		return "", exec.ErrNotFound
+	}}
+	succeeded := stats.DownloadsSucceeded.Load()
+
+	info := Download{Platform: "Vimeo", URL: "https://vimeo.com/1", Title: "Clip"}
+	handleVideoDownload(bot, runner, 1, info, "720p", 1)
+
+	if calls := runner.commands("ffprobe"); len(calls) != 0 {
+		t.Errorf("ffprobe ran %d times although it is missing", len(calls))
+	}
+	if stats.DownloadsSucceeded.Load() != succeeded+1 {
+		t.Error("the download didn't complete")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(methods, "sendVideo") {
+		t.Errorf("the file wasn't sent as a video; requests: %q", methods)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// instanceID distinguishes this bot process's temp files from those of other
+// instances sharing the same download directory
+var instanceID = loadInstanceID()
+
+// loadInstanceID uses INSTANCE_ID if set, then the hostname, then a random value
+func loadInstanceID() string {
+	id := os.Getenv("INSTANCE_ID")
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+
+	// Keep only characters that are safe in file names and glob patterns
+	id = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return -1
+	}, id)
+
+	if id == "" {
+		buf := make([]byte, 4)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	return id
+}
+
+// filePrefix returns the base name for a temp file of the given kind, e.g.
+// "video_host1_1700000000000000000"
+func filePrefix(kind string, timestamp int64) string {
+	return fmt.Sprintf("%s_%s_%d", kind, instanceID, timestamp)
+}
+
+// sanitizeFilename makes a title safe to use as the name of an uploaded file
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 32 {
+			return -1
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "download"
+	}
+	return truncateString(name, 100)
+}
+
+// fileStamp returns the timestamp a download's temp files are named with. A
+// retried download keeps the stamp of the failed attempt so yt-dlp finds and
+// resumes its partial files.
+func fileStamp(info Download) int64 {
+	if info.FileStamp != 0 {
+		return info.FileStamp
+	}
+	return time.Now().UnixNano()
+}
+
+// partialSuffixes mark yt-dlp's unfinished files, which a resumed download may
+// leave next to the finished one
+var partialSuffixes = []string{".part", ".ytdl", ".temp"}
+
+// findOutputFile returns the finished file yt-dlp wrote for prefix, skipping
+// partial downloads left over from an earlier attempt
+func findOutputFile(prefix string) (string, bool) {
+	files, _ := filepath.Glob(prefix + ".*")
+	for _, file := range files {
+		if !isPartialFile(file) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// isPartialFile reports whether a file is an unfinished yt-dlp download,
+// including the per-fragment pieces of fragmented streams
+func isPartialFile(name string) bool {
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) || strings.Contains(name, suffix+"-Frag") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanStalePartials removes partial downloads this instance left behind, e.g.
+// after a crash. Files younger than the download timeout may belong to an
+// attempt that can still be retried and resumed, so only older ones are deleted.
+func cleanStalePartials() {
+	files, _ := filepath.Glob("*_" + instanceID + "_*")
+	removed, kept := 0, 0
+	for _, file := range files {
+		if !isPartialFile(file) {
+			continue
+		}
+		stat, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if time.Since(stat.ModTime()) < downloadTimeout {
+			kept++
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			log.Printf("Failed to remove stale partial file %s: %v", file, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 || kept > 0 {
+		log.Printf("Removed %d stale partial download(s), kept %d recent resumable one(s)", removed, kept)
+	}
+}
+
+// removeStaleTempFiles deletes this instance's temp files and directories that
+// are older than the download timeout, so none of them can belong to a running
+// download. It returns how many were removed and how many bytes that freed.
+func removeStaleTempFiles() (int, int64) {
+	paths, _ := filepath.Glob("*_" + instanceID + "_*")
+	removed, freed := 0, int64(0)
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil || time.Since(stat.ModTime()) < downloadTimeout {
+			continue
+		}
+		size := stat.Size()
+		if stat.IsDir() {
+			size = dirSize(path)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Failed to remove stale temp file %s: %v", path, err)
+			continue
+		}
+		removed++
+		freed += size
+	}
+	return removed, freed
+}
+
+// dirSize adds up the sizes of the files below dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
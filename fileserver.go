@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Defaults for the file server that hands out files too large for Telegram
+const (
+	DefaultFileServerAddr = ":8080"
+	DefaultFileServerDir  = "shared"
+	DefaultFileLinkTTL    = time.Hour
+)
+
+// File server settings, read at startup. The server only runs when
+// FILE_SERVER_BASE_URL, the public address it is reachable at, is set.
+var (
+	fileServerBaseURL = strings.TrimSuffix(os.Getenv("FILE_SERVER_BASE_URL"), "/")
+	fileServerAddr    = loadFileServerAddr()
+	fileServerDir     = loadFileServerDir()
+	fileLinkTTL       = loadFileLinkTTL()
+)
+
+func loadFileServerAddr() string {
+	if addr := os.Getenv("FILE_SERVER_ADDR"); addr != "" {
+		return addr
+	}
+	return DefaultFileServerAddr
+}
+
+func loadFileServerDir() string {
+	if dir := os.Getenv("FILE_SERVER_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultFileServerDir
+}
+
+// loadFileLinkTTL reads FILE_LINK_TTL (in minutes)
+func loadFileLinkTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("FILE_LINK_TTL"))
+	if err != nil || minutes <= 0 {
+		return DefaultFileLinkTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// fileServerStopped is set if the server quits after starting, so no more links
+// to it are handed out
+var fileServerStopped atomic.Bool
+
+// fileServerEnabled reports whether oversized files are offered as links
+func fileServerEnabled() bool {
+	return fileServerBaseURL != "" && !fileServerStopped.Load()
+}
+
+// isShareToken reports whether a file name is a link token, as made by add
+func isShareToken(name string) bool {
+	if len(name) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// sharedFile is a file reachable through a temporary link
+type sharedFile struct {
+	path    string
+	name    string
+	expires time.Time
+}
+
+// fileShares maps link tokens to the files they unlock
+type fileShares struct {
+	mu    sync.Mutex
+	files map[string]sharedFile
+}
+
+var shares = &fileShares{files: make(map[string]sharedFile)}
+
+// add moves path into the served directory and returns the token for it. The
+// file is moved rather than copied so the caller's cleanup leaves it alone.
+func (s *fileShares) add(path, name string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	served := filepath.Join(fileServerDir, token)
+	if err := moveFile(path, served); err != nil {
+		return "", fmt.Errorf("failed to move %s to the file server: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[token] = sharedFile{path: served, name: name, expires: time.Now().Add(fileLinkTTL)}
+	return token, nil
+}
+
+// renameFile is os.Rename, swapped out by tests
+var renameFile = os.Rename
+
+// moveFile moves src to dst. FILE_SERVER_DIR is often on another volume than
+// the downloads, where a rename fails with EXDEV, so the file is copied and the
+// original removed instead.
+func moveFile(src, dst string) error {
+	if err := renameFile(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// get returns the file behind a token unless the link has expired
+func (s *fileShares) get(token string) (sharedFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[token]
+	if !ok || time.Now().After(file.expires) {
+		return sharedFile{}, false
+	}
+	return file, true
+}
+
+// expire deletes the files whose links have run out and forgets their tokens
+func (s *fileShares) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, file := range s.files {
+		if time.Now().After(file.expires) {
+			os.Remove(file.path)
+			delete(s.files, token)
+		}
+	}
+}
+
+// ServeHTTP serves /files/<token>/<name> as a download
+func (s *fileShares) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/files/"), "/")
+	file, ok := s.get(token)
+	if !ok {
+		http.Error(w, "This link has expired or never existed.", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		http.Error(w, "File is no longer available.", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		http.Error(w, "File is no longer available.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", neturl.PathEscape(file.name)))
+	http.ServeContent(w, r, file.name, stat.ModTime(), f)
+}
+
+// removeLeftoverShares deletes the files a previous run shared, whose tokens
+// were lost with it. FILE_SERVER_DIR may hold other files, so only names made
+// by add are touched.
+func removeLeftoverShares() error {
+	entries, err := os.ReadDir(fileServerDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isShareToken(entry.Name()) {
+			os.Remove(filepath.Join(fileServerDir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// startFileServer serves shared files and deletes them once their links expire.
+// Startup fails if the address can't be bound, rather than handing out links to
+// a server that isn't there.
+func startFileServer() error {
+	if !fileServerEnabled() {
+		return nil
+	}
+	if err := os.MkdirAll(fileServerDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create FILE_SERVER_DIR: %w", err)
+	}
+	if err := removeLeftoverShares(); err != nil {
+		return fmt.Errorf("failed to clean up FILE_SERVER_DIR: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", fileServerAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on FILE_SERVER_ADDR: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/files/", shares)
+	go func() {
+		err := http.Serve(listener, mux)
+		fileServerStopped.Store(true)
+		log.Println("File server stopped, oversized files won't be offered as links:", err)
+	}()
+	go func() {
+		for range time.Tick(time.Minute) {
+			shares.expire()
+		}
+	}()
+
+	log.Printf("Serving oversized files on %s as %s", fileServerAddr, fileServerBaseURL)
+	return nil
+}
+
+// sendDownloadLink hands a file that's too large for Telegram over as a
+// temporary link instead, reporting false when the file server is off or the
+// file couldn't be shared
+func sendDownloadLink(bot *tgbotapi.BotAPI, chatID int64, info Download, path string, sizeMB float64) bool {
+	if !fileServerEnabled() {
+		return false
+	}
+
+	name := sanitizeFilename(info.Title) + filepath.Ext(path)
+	token, err := shares.add(path, name)
+	if err != nil {
+		jobLog(info).Println(err)
+		return false
+	}
+	link := fmt.Sprintf("%s/files/%s/%s", fileServerBaseURL, token, neturl.PathEscape(name))
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"📦 This file (%.1f MB) is too large for Telegram, so it's available as a download link instead.\n\n"+
+			"The link expires in %.0f minutes.", sizeMB, fileLinkTTL.Minutes()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("⬇️ Download", link)),
+	)
+	if _, err := sendWithRetry(bot, msg); err != nil {
+		jobLog(info).Println("Failed to send download link:", err)
+		return false
+	}
+	downloadSucceeded(chatID)
+	return true
+}
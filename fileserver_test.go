@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// withFileServer points the file server settings at a temporary directory
+func withFileServer(t *testing.T, addr string) string {
+	t.Helper()
+	dir := t.TempDir()
+	previousURL, previousAddr, previousDir := fileServerBaseURL, fileServerAddr, fileServerDir
+	fileServerBaseURL, fileServerAddr, fileServerDir = "https://files.example.com", addr, dir
+	t.Cleanup(func() {
+		fileServerBaseURL, fileServerAddr, fileServerDir = previousURL, previousAddr, previousDir
+	})
+	return dir
+}
+
+func TestRemoveLeftoverSharesKeepsOtherFiles(t *testing.T) {
+	dir := withFileServer(t, "")
+	token := "0123456789abcdef0123456789abcdef"
+
+	for _, name := range []string{token, "notes.txt", "0123456789ABCDEF0123456789ABCDEF", "0123456789abcdef"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "fedcba9876543210fedcba9876543210"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeLeftoverShares(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, token)); !os.IsNotExist(err) {
+		t.Error("a leftover shared file wasn't removed")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 4 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("other files were touched, left with %q", names)
+	}
+}
+
+func TestStartFileServerFailsWhenAddressIsTaken(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	withFileServer(t, taken.Addr().String())
+
+	if err := startFileServer(); err == nil {
+		t.Error("startFileServer succeeded on an address that's already in use")
+	}
+}
+
+// Across filesystems a rename fails with EXDEV, and the file is copied instead
+func TestSharesAddAcrossFilesystems(t *testing.T) {
+	dir := withFileServer(t, "")
+	renameFile = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("video"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := shares.add(path, "video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		shares.mu.Lock()
+		delete(shares.files, token)
+		shares.mu.Unlock()
+	})
+
+	if data, err := os.ReadFile(filepath.Join(dir, token)); err != nil || string(data) != "video" {
+		t.Errorf("served file = %q, %v; want the download's contents", data, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the original file was left behind")
+	}
+}
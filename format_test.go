@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveFormatCode(t *testing.T) {
+	// Overrides replace the built-in selector for their platform and quality only
+	formatOverrides[formatOverrideKey("Vimeo", "720p")] = "custom-720"
+	formatOverrides[formatOverrideKey("TikTok", "nowm")] = "custom-nowm"
+	t.Cleanup(func() {
+		delete(formatOverrides, formatOverrideKey("Vimeo", "720p"))
+		delete(formatOverrides, formatOverrideKey("TikTok", "nowm"))
+	})
+
+	tests := []struct {
+		platform, quality, want string
+	}{
+		{"YouTube", "360p", "18/bestvideo[height<=360]+bestaudio/best[height<=360]"},
+		{"YouTube", "480p", "135+bestaudio/bestvideo[height<=480]+bestaudio/best[height<=480]"},
+		{"YouTube", "720p", "22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]"},
+		{"YouTube", "1080p", "bestvideo[height<=1080]+bestaudio/best[height<=1080]"},
+		{"YouTube", "2160p", "bestvideo[height<=2160]+bestaudio/best[height<=2160]"},
+		{"YouTube", "best", "best"},
+		{"YouTube", "medium", "best"},
+		{"YouTube", "nowm", "best"},
+		{"YouTube", "original", "b"},
+		{"YouTube", "smallest", "worst[ext=mp4]/worst/wv*+wa"},
+
+		{"Instagram", "720p", "bestvideo[height<=720]+bestaudio/best[height<=720]"},
+		{"Instagram", "medium", "worst[ext=mp4]/worst"},
+		{"Instagram", "best", "best[ext=mp4]/best"},
+		{"Instagram", "original", "b"},
+		{"Facebook", "480p", "bestvideo[height<=480]+bestaudio/best[height<=480]"},
+		{"Facebook", "medium", "worst[ext=mp4]/worst"},
+		{"Facebook", "best", "best[ext=mp4]/best"},
+		{"TikTok", "1080p", "bestvideo[height<=1080]+bestaudio/best[height<=1080]"},
+		{"TikTok", "medium", "worst[ext=mp4]/worst"},
+		{"TikTok", "best", "best[ext=mp4]/best"},
+		{"TikTok", "smallest", "worst[ext=mp4]/worst/wv*+wa"},
+
+		{"Vimeo", "1080p", "bestvideo[height<=1080]+bestaudio/best[height<=1080]"},
+		{"Vimeo", "best", "best"},
+		{"Vimeo", "medium", "best"},
+		{"Vimeo", "original", "b"},
+		{"Unknown", "360p", "bestvideo[height<=360]+bestaudio/best[height<=360]"},
+		{"Unknown", "best", "best"},
+		{"Unknown", "nowm", "best"},
+
+		{"Vimeo", "720p", "custom-720"},
+		{"TikTok", "nowm", "custom-nowm"},
+		{"YouTube", "720p", "22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]"},
+	}
+
+	for _, tt := range tests {
+		if got := resolveFormatCode(tt.platform, tt.quality); got != tt.want {
+			t.Errorf("resolveFormatCode(%q, %q) = %q, want %q", tt.platform, tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestWithAudioLanguage(t *testing.T) {
+	tests := []struct {
+		formatCode, language, want string
+	}{
+		{"best", "", "best"},
+		{"best", "de", "bestvideo+bestaudio[language=de]/best"},
+		{
+			"22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]", "de",
+			"136+bestaudio[language=de]/bestvideo[height<=720]+bestaudio[language=de]/" +
+				"22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := withAudioLanguage(tt.formatCode, tt.language); got != tt.want {
+			t.Errorf("withAudioLanguage(%q, %q) = %q, want %q", tt.formatCode, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestTikTokNoWatermarkFormat(t *testing.T) {
+	nowm := resolveFormatCode("TikTok", "nowm")
+	for _, quality := range []string{"best", "medium", "720p"} {
+		if standard := resolveFormatCode("TikTok", quality); nowm == standard {
+			t.Errorf("no-watermark selector %q is the same as the %s one", nowm, quality)
+		}
+	}
+
+	// The watermarked download_addr format stays as the last resort
+	if !strings.HasPrefix(nowm, "best[format_id!^=download]") || !strings.HasSuffix(nowm, "/best") {
+		t.Errorf("no-watermark selector %q should skip download_addr first and fall back to best", nowm)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxFormatSelectorLength keeps /dl selectors to a sane size
+const MaxFormatSelectorLength = 200
+
+// formatSelectorPattern allows the characters yt-dlp's format syntax uses, such
+// as "137+140" or "bv*[height<=720]+ba/b", and nothing a shell would interpret.
+// Commands are never run through a shell, but a selector has no reason to
+// contain anything else either.
+var formatSelectorPattern = regexp.MustCompile(`^[A-Za-z0-9_.+\-/*\[\]<>=!^~?:,]+$`)
+
+const dlUsage = "✍️ Usage: /dl <url> <format>\n\n" +
+	"For example: /dl https://youtu.be/dQw4w9WgXcQ 137+140\n\n" +
+	"The format is passed to yt-dlp's -f option, so IDs from its format list and selectors like bv*[height<=720]+ba both work."
+
+// validFormatSelector reports whether a user-supplied selector is safe to pass to -f
+func validFormatSelector(selector string) bool {
+	return len(selector) <= MaxFormatSelectorLength &&
+		!strings.HasPrefix(selector, "-") &&
+		formatSelectorPattern.MatchString(selector)
+}
+
+// handleDownloadCommand downloads a link with an exact format selector given
+// by the user, skipping the quality keyboard
+func handleDownloadCommand(bot *tgbotapi.BotAPI, runner Runner, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, dlUsage))
+		return
+	}
+	if !validFormatSelector(args[1]) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ That format selector contains characters yt-dlp formats don't use."))
+		return
+	}
+	statusMsg, err := sendWithRetry(bot, tgbotapi.NewMessage(chatID, "🔍 Fetching info..."))
+	if err != nil {
+		log.Println("Failed to send status message:", err)
+		return
+	}
+
+	url, problem := checkLink(args[0])
+	if problem != "" {
+		bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, problem))
+		return
+	}
+
+	// Only a link that will really be downloaded counts against the quota
+	if message.From != nil {
+		if ok, resetAt := quotas.consume(message.From.ID); !ok {
+			bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, quotaExceededText(resetAt)))
+			return
+		}
+	}
+
+	stats.LinksReceived.Add(1)
+	info := Download{
+		URL:            url,
+		Platform:       detectPlatform(url),
+		Title:          "Title unavailable",
+		FormatSelector: args[1],
+	}
+	if meta := getVideoInfo(runner, url); meta != nil {
+		info.Meta = meta
+		info.Title = meta.Title
+		info.Thumbnail = meta.Thumbnail
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID,
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("⏳ "+bold("Processing %s download")+"\n\n%s\n\n0%% complete...", escapeText(args[1]), title)
+		}),
+	)
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+
+	// Mark it running as a button press would, so /retry can't overlap it
+	urlCache.tryStart(getCacheKey(chatID, statusMsg.MessageID))
+
+	// The selector doubles as the quality label in status messages and captions
+	startDownload(bot, runner, chatID, info, "video", info.FormatSelector, statusMsg.MessageID)
+}
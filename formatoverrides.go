@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// formatOverrides replace built-in format selectors without a rebuild. They are
+// read at startup from variables named <PLATFORM>_<QUALITY>_FORMAT, for example
+// YOUTUBE_720P_FORMAT or TIKTOK_NOWM_FORMAT, and keyed by formatOverrideKey.
+var formatOverrides = loadFormatOverrides()
+
+func formatOverrideKey(platform, quality string) string {
+	return strings.ToUpper(platform + "_" + quality + "_FORMAT")
+}
+
+func loadFormatOverrides() map[string]string {
+	overrides := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasSuffix(name, "_FORMAT") || strings.TrimSpace(value) == "" {
+			continue
+		}
+		for _, platform := range supportedPlatforms {
+			if strings.HasPrefix(name, strings.ToUpper(platform)+"_") {
+				overrides[name] = strings.TrimSpace(value)
+				log.Printf("Using %s=%q instead of the built-in format selector", name, value)
+				break
+			}
+		}
+	}
+	return overrides
+}
+
+// formatOverride returns the configured selector for a platform and quality, if any
+func formatOverride(platform, quality string) (string, bool) {
+	code, ok := formatOverrides[formatOverrideKey(platform, quality)]
+	return code, ok
+}
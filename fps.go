@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxLimitedFPS is the frame rate the 30fps toggle caps videos at
+const MaxLimitedFPS = 30
+
+// hasHighFrameRate reports whether any of a video's formats runs above MaxLimitedFPS
+func hasHighFrameRate(meta *VideoMetadata) bool {
+	if meta == nil {
+		return false
+	}
+	for _, f := range meta.Formats {
+		if f.HasVideo() && f.FPS > MaxLimitedFPS {
+			return true
+		}
+	}
+	return false
+}
+
+// withFPSLimit prefers variants of at most MaxLimitedFPS, so a video offered
+// only at 60fps still downloads at its highest frame rate
+func withFPSLimit(formatCode string) string {
+	return withPreferredFilter(formatCode, fmt.Sprintf("[fps<=%d]", MaxLimitedFPS))
+}
+
+// withPreferredFilter prefers formats matching filter. Every alternative that
+// picks by quality (best, bestvideo, worst...) gets the filter, and the original
+// selector follows as the fallback. Alternatives naming a fixed format ID are
+// left to the fallback.
+func withPreferredFilter(formatCode, filter string) string {
+	var limited []string
+	for _, alternative := range strings.Split(formatCode, "/") {
+		name := alternative
+		if end := strings.IndexAny(name, "[+"); end >= 0 {
+			name = name[:end]
+		}
+		if !strings.HasPrefix(name, "b") && !strings.HasPrefix(name, "w") {
+			continue
+		}
+		limited = append(limited, name+filter+alternative[len(name):])
+	}
+	if len(limited) == 0 {
+		return formatCode
+	}
+	return strings.Join(limited, "/") + "/" + formatCode
+}
+
+// createFPSRow is the on/off switch for preferring 30fps variants, shown only
+// when the video is offered at a higher frame rate
+func createFPSRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if !hasHighFrameRate(info.Meta) {
+		return nil
+	}
+	label := "🎞 30fps: off"
+	if info.LimitFPS {
+		label = "🎞 30fps: on ✅ (smaller)"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "fps30:toggle"))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// countryCodePattern matches an ISO 3166-1 alpha-2 code such as "US"
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// geoArgs are yt-dlp's region lock workarounds, added to every invocation when
+// GEO_BYPASS is enabled. GEO_BYPASS_COUNTRY picks the country to pose as;
+// without it yt-dlp chooses one itself.
+var geoArgs = loadGeoArgs()
+
+func loadGeoArgs() []string {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("GEO_BYPASS"))) {
+	case "true", "1", "on", "yes":
+	default:
+		return nil
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(os.Getenv("GEO_BYPASS_COUNTRY")))
+	if country == "" {
+		return []string{"--geo-bypass"}
+	}
+	if !countryCodePattern.MatchString(country) {
+		log.Printf("Ignoring invalid GEO_BYPASS_COUNTRY %q, expected a two-letter country code", country)
+		return []string{"--geo-bypass"}
+	}
+	return []string{"--geo-bypass-country", country}
+}
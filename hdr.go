@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sdrFilter selects standard dynamic range formats, and formats that don't say
+const sdrFilter = "[dynamic_range=?SDR]"
+
+// hasHDR reports whether any of a video's formats is HDR (HDR10, HLG, Dolby Vision...)
+func hasHDR(meta *VideoMetadata) bool {
+	if meta == nil {
+		return false
+	}
+	for _, f := range meta.Formats {
+		if f.HasVideo() && f.DynamicRange != "" && f.DynamicRange != "SDR" {
+			return true
+		}
+	}
+	return false
+}
+
+// withSDRPreference prefers SDR variants, falling back to HDR ones when that is
+// all a resolution comes in; those are tone-mapped after the download
+func withSDRPreference(formatCode string) string {
+	return withPreferredFilter(formatCode, sdrFilter)
+}
+
+// createHDRRow is the switch between SDR, the default since HDR looks washed out
+// on most screens and in Telegram's player, and keeping the HDR original. It is
+// only shown when the video is offered in HDR.
+func createHDRRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if !hasHDR(info.Meta) {
+		return nil
+	}
+	label := "🌈 Colors: SDR (tone-mapped) ✅"
+	if info.KeepHDR {
+		label = "🌈 Colors: HDR original"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "hdr:toggle"))
+}
+
+// isHDRTransfer reports whether an ffprobe color_transfer is an HDR curve
+// (PQ for HDR10 and Dolby Vision, HLG for broadcast HDR)
+func isHDRTransfer(transfer string) bool {
+	return transfer == "smpte2084" || transfer == "arib-std-b67"
+}
+
+// toneMapToSDR re-encodes an HDR video to SDR in BT.709, so its colors look
+// right on ordinary displays. It needs an ffmpeg built with zimg.
+func toneMapToSDR(runner Runner, path string) (string, error) {
+	output := strings.TrimSuffix(path, filepath.Ext(path)) + "_sdr.mp4"
+	_, err := runner.Run(context.Background(), "ffmpeg",
+		"-y",
+		"-v", "error",
+		"-i", path,
+		"-vf", "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,"+
+			"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		output,
+	)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg tone mapping failed: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPostDownloadHookTimeout bounds the hook when POST_DOWNLOAD_HOOK_TIMEOUT is unset
+const DefaultPostDownloadHookTimeout = 60 * time.Second
+
+// postDownloadHook is an executable from POST_DOWNLOAD_HOOK that is run with
+// each downloaded file's path before it is uploaded, e.g. to scan or transcode
+// it. A non-zero exit stops the upload. Empty means no hook.
+var postDownloadHook = strings.TrimSpace(os.Getenv("POST_DOWNLOAD_HOOK"))
+
+// postDownloadHookTimeout is read from POST_DOWNLOAD_HOOK_TIMEOUT (in seconds) at startup
+var postDownloadHookTimeout = loadPostDownloadHookTimeout()
+
+func loadPostDownloadHookTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("POST_DOWNLOAD_HOOK_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		return DefaultPostDownloadHookTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// hookRejectedMessage is shown when the hook refuses a file
+const hookRejectedMessage = "❌ The downloaded file didn't pass the server's checks, so it wasn't sent."
+
+// runPostDownloadHook runs the configured hook on a downloaded file. The hook
+// may change the file in place; callers must look at it again afterwards.
+func runPostDownloadHook(runner Runner, path string) error {
+	if postDownloadHook == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postDownloadHookTimeout)
+	defer cancel()
+
+	output, err := runner.Run(ctx, postDownloadHook, path)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("post-download hook timed out after %s", postDownloadHookTimeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		output = append(output, exitErr.Stderr...)
+	}
+	return fmt.Errorf("post-download hook failed: %w\n%s", err, lastLines(string(output), 5))
+}
+
+// filterByPostDownloadHook runs the hook on each of a batch of files, such as
+// the tracks of a playlist or the items of a post, and returns the ones it
+// accepted. Rejected files are logged and left out.
+func filterByPostDownloadHook(runner Runner, info Download, files []string) []string {
+	if postDownloadHook == "" {
+		return files
+	}
+	var accepted []string
+	for _, file := range files {
+		if err := runPostDownloadHook(runner, file); err != nil {
+			jobLog(info).Printf("%s: %v", filepath.Base(file), err)
+			continue
+		}
+		accepted = append(accepted, file)
+	}
+	return accepted
+}
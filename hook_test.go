@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFilterByPostDownloadHook(t *testing.T) {
+	previous := postDownloadHook
+	postDownloadHook = "/usr/local/bin/scan"
+	t.Cleanup(func() { postDownloadHook = previous })
+
+	runner := &fakeRunner{respond: func(call fakeCall) (string, error) {
+		if call.args[0] == "/tmp/b.mp3" {
+			return "infected", errors.New("exit status 1")
+		}
+		return "", nil
+	}}
+	files := []string{"/tmp/a.mp3", "/tmp/b.mp3", "/tmp/c.mp3"}
+
+	got := filterByPostDownloadHook(runner, Download{}, files)
+	if want := []string{"/tmp/a.mp3", "/tmp/c.mp3"}; !slices.Equal(got, want) {
+		t.Errorf("accepted %q, want %q", got, want)
+	}
+	if calls := runner.commands(postDownloadHook); len(calls) != len(files) {
+		t.Errorf("hook ran %d times, want once per file (%d)", len(calls), len(files))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxInfoJSONSize is how big the metadata sidecar may get before the bulky
+// technical fields are stripped from it
+const MaxInfoJSONSize = 5 * 1024 * 1024
+
+// bulkyInfoJSONFields are dropped, in order, from an oversized sidecar. They
+// describe streams rather than the video, so researchers rarely need them.
+var bulkyInfoJSONFields = []string{
+	"comments",
+	"formats",
+	"requested_formats",
+	"automatic_captions",
+	"subtitles",
+	"thumbnails",
+	"heatmap",
+}
+
+// infoJSONFile is where yt-dlp writes the sidecar for a download with the given
+// file prefix. It sits outside the prefix's "prefix.*" glob on purpose.
+func infoJSONFile(prefix string) string {
+	return prefix + "_meta.info.json"
+}
+
+// infoJSONArgs asks yt-dlp to write the sidecar when the user opted in
+func infoJSONArgs(info Download, prefix string) []string {
+	if !info.WithInfoJSON {
+		return nil
+	}
+	return []string{"--write-info-json", "-o", "infojson:" + prefix + "_meta.%(ext)s"}
+}
+
+// createInfoJSONRow is the on/off switch for the metadata sidecar
+func createInfoJSONRow(info Download) []tgbotapi.InlineKeyboardButton {
+	label := "🧾 Attach metadata JSON: off"
+	if info.WithInfoJSON {
+		label = "🧾 Attach metadata JSON: on ✅"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "infojson:toggle"))
+}
+
+// sendInfoJSON sends the sidecar as a document, trimming it first if it's huge
+func sendInfoJSON(bot *tgbotapi.BotAPI, chatID int64, info Download, path string) {
+	if !info.WithInfoJSON {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		jobLog(info).Println("Failed to read info.json:", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "🧾 The metadata file couldn't be created for this video."))
+		return
+	}
+
+	note := ""
+	if len(data) > MaxInfoJSONSize {
+		if data, err = trimInfoJSON(data); err != nil {
+			jobLog(info).Println("Failed to trim info.json:", err)
+			bot.Send(tgbotapi.NewMessage(chatID, "🧾 The metadata file is too large to send."))
+			return
+		}
+		note = "\n▫️ Stream details were removed to keep the file small"
+	}
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  sanitizeFilename(info.Title) + ".info.json",
+		Bytes: data,
+	})
+	document.Caption = buildCaption(info.Title, func(title string) string {
+		return fmt.Sprintf("🧾 "+bold("Metadata")+" - %s%s", title, note)
+	})
+	document.ParseMode = parseMode
+	if _, err := sendWithRetry(bot, document); err != nil {
+		jobLog(info).Println("Failed to send info.json:", err)
+	}
+}
+
+// trimInfoJSON removes bulky fields until the JSON fits within MaxInfoJSONSize
+func trimInfoJSON(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, field := range bulkyInfoJSONFields {
+		delete(fields, field)
+		trimmed, err := json.MarshalIndent(fields, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if len(trimmed) <= MaxInfoJSONSize {
+			return trimmed, nil
+		}
+	}
+	return nil, fmt.Errorf("still larger than %d bytes after trimming", MaxInfoJSONSize)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram limits for media groups and photos
+const (
+	MaxMediaGroupSize = 10
+	MaxPhotoSize      = 10 * 1024 * 1024 // larger photos must go as documents
+)
+
+// Kinds of Instagram links, told apart by instagramKind
+const (
+	InstagramPost      = "post"
+	InstagramReel      = "reel"
+	InstagramIGTV      = "igtv"
+	InstagramStory     = "story"
+	InstagramHighlight = "highlight"
+)
+
+// highlightLoginMessage explains why highlights can't be fetched without a session
+const highlightLoginMessage = "🔑 Instagram highlights can only be downloaded with a login, and the bot doesn't have one."
+
+// instagramKind works out what an Instagram link points at, or "" for pages
+// such as profiles. Highlights come as /stories/highlights/<id> or as /s/<id>
+// share links; posts, reels and IGTV may have the username in front.
+func instagramKind(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case len(segments) >= 3 && segments[0] == "stories" && segments[1] == "highlights",
+		len(segments) >= 2 && segments[0] == "s":
+		return InstagramHighlight
+	case len(segments) >= 3 && segments[0] == "stories":
+		return InstagramStory
+	}
+	for i, segment := range segments {
+		if i+1 >= len(segments) || i > 1 {
+			break
+		}
+		switch segment {
+		case "p":
+			return InstagramPost
+		case "reel", "reels":
+			return InstagramReel
+		case "tv":
+			return InstagramIGTV
+		}
+	}
+	return ""
+}
+
+// isInstagramImagePost reports whether an Instagram link looks like a photo or
+// carousel post that yt-dlp couldn't turn into a video
+func isInstagramImagePost(info Download) bool {
+	if info.Platform != "Instagram" || instagramKind(info.URL) != InstagramPost {
+		return false
+	}
+	return len(availableHeights(info.Meta)) == 0
+}
+
+// isInstagramHighlight reports whether the link is a story highlight. Those hold
+// several photos and clips, so they go through gallery-dl and the media group
+// sender like carousels do.
+func isInstagramHighlight(info Download) bool {
+	return info.Platform == "Instagram" && instagramKind(info.URL) == InstagramHighlight
+}
+
+func createImageKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼 Photos", "image:photo"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Original Files (full resolution)", "image:document"),
+		),
+	)
+}
+
+// splitMediaGroups divides a carousel into as few media groups as Telegram
+// allows, evening out their sizes so none is left with the single item that
+// sendMediaGroup refuses
+func splitMediaGroups(files []string) [][]string {
+	count := (len(files) + MaxMediaGroupSize - 1) / MaxMediaGroupSize
+	var groups [][]string
+	for g := 0; g < count; g++ {
+		start, end := g*len(files)/count, (g+1)*len(files)/count
+		groups = append(groups, files[start:end])
+	}
+	return groups
+}
+
+// isImageFile reports whether a downloaded file is a picture rather than a clip
+func isImageFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".webp", ".heic":
+		return true
+	}
+	return false
+}
+
+// handleImageDownload fetches every item of an Instagram post with gallery-dl,
+// which unlike yt-dlp understands photo posts, and sends them as a single photo
+// or as media groups for carousels. mode is "photo" or "document".
+func handleImageDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, mode string, statusMsgID int) {
+	dir := filePrefix("images", time.Now().UnixNano())
+	defer os.RemoveAll(dir)
+
+	galleryArgs := append([]string{"-D", dir}, cookieArgs(info.URL)...)
+	if output, err := runner.Run(context.Background(), "gallery-dl", append(galleryArgs, info.URL)...); err != nil {
+		jobLog(info).Println("Image download error:", err)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			output = append(output, exitErr.Stderr...)
+		}
+		if needsInstagramLogin(info, string(output)) {
+			reportInstagramLogin(bot, chatID)
+			return
+		}
+		sendFailure(bot, chatID, "❌ Failed to download images from this post.")
+		return
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	sort.Strings(files)
+	if len(files) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ No images found in this post."))
+		return
+	}
+
+	files = filterByPostDownloadHook(runner, info, files)
+	if len(files) == 0 {
+		sendFailure(bot, chatID, hookRejectedMessage)
+		return
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+		fmt.Sprintf("✅ "+bold("Download Complete!")+"\n\nUploading %d file(s) to Telegram...", len(files)))
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+
+	caption := buildCaption(info.Title, func(title string) string {
+		return fmt.Sprintf("📷 "+bold("%s")+" - %s", info.Platform, title)
+	})
+
+	// A single image goes out on its own
+	if len(files) == 1 {
+		file := files[0]
+		stat, err := os.Stat(file)
+		if mode == "photo" && isImageFile(file) && err == nil && stat.Size() <= MaxPhotoSize {
+			photo := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(file))
+			photo.Caption = caption
+			photo.ParseMode = parseMode
+			_, err = sendWithRetry(bot, photo)
+		} else {
+			document := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(file))
+			document.Caption = caption
+			document.ParseMode = parseMode
+			_, err = sendWithRetry(bot, document)
+		}
+		if err != nil {
+			jobLog(info).Println("Failed to send image:", err)
+			sendFailure(bot, chatID, "❌ Failed to send image.")
+			return
+		}
+		downloadSucceeded(chatID)
+		return
+	}
+
+	// Carousels are sent in media groups of up to ten items
+	for g, group := range splitMediaGroups(files) {
+		var media []interface{}
+		for i, file := range group {
+			var item interface{}
+			switch {
+			case mode == "document":
+				doc := tgbotapi.NewInputMediaDocument(tgbotapi.FilePath(file))
+				if g == 0 && i == 0 {
+					doc.Caption = caption
+					doc.ParseMode = parseMode
+				}
+				item = doc
+			case isImageFile(file):
+				photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FilePath(file))
+				if g == 0 && i == 0 {
+					photo.Caption = caption
+					photo.ParseMode = parseMode
+				}
+				item = photo
+			default:
+				video := tgbotapi.NewInputMediaVideo(tgbotapi.FilePath(file))
+				if g == 0 && i == 0 {
+					video.Caption = caption
+					video.ParseMode = parseMode
+				}
+				item = video
+			}
+			media = append(media, item)
+		}
+
+		err := retryTransient(func() error {
+			_, err := bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+			return err
+		})
+		if err != nil {
+			jobLog(info).Println("Failed to send media group:", err)
+			sendFailure(bot, chatID, "❌ Failed to send some of the images.")
+			return
+		}
+	}
+	downloadSucceeded(chatID)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSplitMediaGroups(t *testing.T) {
+	tests := []struct {
+		items int
+		sizes []int
+	}{
+		{2, []int{2}},
+		{10, []int{10}},
+		{11, []int{5, 6}},
+		{20, []int{10, 10}},
+		{21, []int{7, 7, 7}},
+		{31, []int{7, 8, 8, 8}},
+	}
+
+	for _, tt := range tests {
+		files := make([]string, tt.items)
+		for i := range files {
+			files[i] = fmt.Sprintf("%02d.jpg", i)
+		}
+
+		groups := splitMediaGroups(files)
+		var sizes []int
+		var joined []string
+		for _, group := range groups {
+			sizes = append(sizes, len(group))
+			joined = append(joined, group...)
+		}
+		if !slices.Equal(sizes, tt.sizes) {
+			t.Errorf("%d items split into groups of %v, want %v", tt.items, sizes, tt.sizes)
+		}
+		if !slices.Equal(joined, files) {
+			t.Errorf("%d items: groups %q don't keep the post's order", tt.items, groups)
+		}
+	}
+}
+
+func TestInstagramKind(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://www.instagram.com/p/Cabc123/", InstagramPost},
+		{"https://www.instagram.com/p/Cabc123/?img_index=2", InstagramPost},
+		{"https://www.instagram.com/someuser/p/Cabc123/", InstagramPost},
+		{"https://www.instagram.com/reel/Cabc123/", InstagramReel},
+		{"https://www.instagram.com/reels/Cabc123/", InstagramReel},
+		{"https://www.instagram.com/someuser/reel/Cabc123/", InstagramReel},
+		{"https://www.instagram.com/tv/Cabc123/", InstagramIGTV},
+		{"https://www.instagram.com/someuser/tv/Cabc123", InstagramIGTV},
+		{"https://www.instagram.com/stories/highlights/17900000000000000/", InstagramHighlight},
+		{"https://www.instagram.com/s/aGlnaGxpZ2h0OjE3OTAw?story_media_id=1", InstagramHighlight},
+		{"https://www.instagram.com/stories/someuser/3100000000000000000/", InstagramStory},
+
+		// Profiles and other pages aren't downloadable
+		{"https://www.instagram.com/someuser/", ""},
+		{"https://www.instagram.com/p/", ""},
+		{"https://www.instagram.com/stories/someuser/", ""},
+		{"https://www.instagram.com/explore/tags/cats/", ""},
+		{"https://www.instagram.com/", ""},
+	}
+
+	for _, tt := range tests {
+		if got := instagramKind(tt.url); got != tt.want {
+			t.Errorf("instagramKind(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// instagramCookieFile is the Netscape cookies file built from INSTAGRAM_SESSIONID,
+// empty when no session is configured
+var instagramCookieFile string
+
+// instagramLoginMessage is shown when Instagram wants a login the bot doesn't have
+const instagramLoginMessage = "🔑 This Instagram content requires a login and isn't available to the bot."
+
+// loadInstagramSession turns INSTAGRAM_SESSIONID into a cookies file that yt-dlp
+// and gallery-dl can read. The session ID is a credential: it is only ever
+// written to that owner-only file, never logged.
+func loadInstagramSession() error {
+	sessionID := strings.TrimSpace(os.Getenv("INSTAGRAM_SESSIONID"))
+	if sessionID == "" {
+		return nil
+	}
+	if strings.ContainsAny(sessionID, "\t\r\n") {
+		return fmt.Errorf("INSTAGRAM_SESSIONID contains invalid characters")
+	}
+
+	expires := time.Now().AddDate(1, 0, 0).Unix()
+	contents := "# Netscape HTTP Cookie File\n" +
+		fmt.Sprintf(".instagram.com\tTRUE\t/\tTRUE\t%d\tsessionid\t%s\n", expires, sessionID)
+
+	path := filepath.Join(os.TempDir(), "instagram_cookies_"+instanceID+".txt")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		return fmt.Errorf("failed to write Instagram cookies file: %w", err)
+	}
+	instagramCookieFile = path
+	return nil
+}
+
+// cookieArgs returns the --cookies option for links that need a session
+func cookieArgs(url string) []string {
+	if instagramCookieFile != "" && detectPlatform(url) == "Instagram" {
+		return []string{"--cookies", instagramCookieFile}
+	}
+	return nil
+}
+
+// needsInstagramLogin reports whether a failed Instagram download was refused
+// for lack of a (valid) login
+func needsInstagramLogin(info Download, output string) bool {
+	if info.Platform != "Instagram" {
+		return false
+	}
+	lower := strings.ToLower(output)
+	for _, pattern := range []string{"login required", "log in to", "private account", "this account is private", "requested content is not available", "login page"} {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
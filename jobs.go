@@ -0,0 +1,32 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// newJobID returns a short random ID that ties together the log lines of one
+// link's downloads, which otherwise interleave with every other job's
+func newJobID() string {
+	buf := make([]byte, 3)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// jobLog returns a logger that prefixes every line with the download's job ID
+func jobLog(info Download) *log.Logger {
+	if info.JobID == "" {
+		return log.Default()
+	}
+	return log.New(log.Writer(), "["+info.JobID+"] ", log.Flags()|log.Lmsgprefix)
+}
+
+// supportReference is appended to error messages so users can quote the job
+// when asking for help
+func supportReference(info Download) string {
+	if info.JobID == "" {
+		return ""
+	}
+	return "\n\nReference: " + info.JobID
+}
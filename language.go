@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// audioLanguages lists the distinct audio track languages of a video, original
+// track first. It returns nil unless there is an actual choice to make.
+func audioLanguages(meta *VideoMetadata) []string {
+	if meta == nil {
+		return nil
+	}
+
+	type track struct {
+		language   string
+		preference int
+		original   bool
+	}
+	seen := make(map[string]int)
+	var tracks []track
+	for _, f := range meta.Formats {
+		if !f.HasAudio() || f.Language == "" {
+			continue
+		}
+		original := strings.Contains(strings.ToLower(f.FormatNote), "original")
+		if i, ok := seen[f.Language]; ok {
+			tracks[i].original = tracks[i].original || original
+			continue
+		}
+		seen[f.Language] = len(tracks)
+		tracks = append(tracks, track{f.Language, f.LanguagePreference, original})
+	}
+
+	if len(tracks) < 2 {
+		return nil
+	}
+
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].original != tracks[j].original {
+			return tracks[i].original
+		}
+		return tracks[i].preference > tracks[j].preference
+	})
+
+	languages := make([]string, len(tracks))
+	for i, t := range tracks {
+		languages[i] = t.language
+	}
+	return languages
+}
+
+// createLanguageRows builds the audio language picker, marking the selected
+// track (or the original one when nothing was picked yet)
+func createLanguageRows(info Download) [][]tgbotapi.InlineKeyboardButton {
+	languages := audioLanguages(info.Meta)
+	if len(languages) == 0 {
+		return nil
+	}
+
+	selected := info.AudioLanguage
+	if selected == "" {
+		selected = languages[0]
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, language := range languages {
+		label := "🗣 " + language
+		if language == selected {
+			label += " ✅"
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "lang:"+language))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// withAudioLanguage rewrites a format selector so it prefers the given audio
+// language, keeping the original selector as a fallback
+func withAudioLanguage(formatCode, language string) string {
+	if language == "" {
+		return formatCode
+	}
+
+	languageAudio := fmt.Sprintf("+bestaudio[language=%s]", language)
+
+	var preferred []string
+	for _, alternative := range strings.Split(formatCode, "/") {
+		if strings.Contains(alternative, "+bestaudio") {
+			preferred = append(preferred, strings.Replace(alternative, "+bestaudio", languageAudio, 1))
+		}
+	}
+	if len(preferred) == 0 {
+		preferred = []string{"bestvideo" + languageAudio}
+	}
+
+	return strings.Join(preferred, "/") + "/" + formatCode
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Keyboard layouts: "detailed" lists every quality and option, "compact" only
+// the best video and MP3 with a button to expand
+const (
+	LayoutDetailed = "detailed"
+	LayoutCompact  = "compact"
+)
+
+// DefaultLayoutFile is where the chats' layout preferences are kept
+const DefaultLayoutFile = "layouts.json"
+
+// layoutStore remembers each chat's keyboard layout across restarts. Chats
+// without a preference get the detailed layout.
+type layoutStore struct {
+	mu      sync.Mutex
+	path    string
+	Layouts map[int64]string `json:"layouts"`
+}
+
+// layouts is persisted to LAYOUT_FILE
+var layouts = loadLayoutStore()
+
+func loadLayoutStore() *layoutStore {
+	store := &layoutStore{path: os.Getenv("LAYOUT_FILE"), Layouts: make(map[int64]string)}
+	if store.path == "" {
+		store.path = DefaultLayoutFile
+	}
+
+	data, err := os.ReadFile(store.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Printf("Failed to read %s, using the default layout: %v", store.path, err)
+	default:
+		if err := json.Unmarshal(data, store); err != nil {
+			log.Printf("Failed to parse %s, using the default layout: %v", store.path, err)
+		}
+		if store.Layouts == nil {
+			store.Layouts = make(map[int64]string)
+		}
+	}
+	return store
+}
+
+// compact reports whether the chat asked for the compact layout
+func (l *layoutStore) compact(chatID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Layouts[chatID] == LayoutCompact
+}
+
+// set records the chat's layout and saves the preferences
+func (l *layoutStore) set(chatID int64, layout string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if layout == LayoutDetailed {
+		delete(l.Layouts, chatID)
+	} else {
+		l.Layouts[chatID] = layout
+	}
+	l.save()
+}
+
+// save writes the preferences the same crash-safe way as the quotas. The
+// caller holds l.mu.
+func (l *layoutStore) save() {
+	data, err := json.Marshal(l)
+	if err != nil {
+		log.Println("Failed to encode layouts:", err)
+		return
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Println("Failed to save layouts:", err)
+		return
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		log.Println("Failed to save layouts:", err)
+	}
+}
+
+// createCompactKeyboard covers the common cases in one row, plus a way to the
+// full keyboard for this message
+func createCompactKeyboard(info Download) tgbotapi.InlineKeyboardMarkup {
+	video := tgbotapi.NewInlineKeyboardButtonData("📹 Best video", "video:best")
+	if info.Platform == "TikTok" {
+		video = tgbotapi.NewInlineKeyboardButtonData("📹 Best video", "video:nowm")
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(video, tgbotapi.NewInlineKeyboardButtonData("🎵 MP3", "audio:mp3")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ More options", "layout:"+LayoutDetailed)),
+	)
+}
+
+// handleLayout shows or changes the chat's keyboard layout via
+// /layout [compact|detailed]
+func handleLayout(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	switch layout := strings.ToLower(strings.TrimSpace(message.CommandArguments())); layout {
+	case LayoutCompact, LayoutDetailed:
+		layouts.set(chatID, layout)
+		bot.Send(tgbotapi.NewMessage(chatID, "⌨️ Download keyboards will now be "+layout+"."))
+	default:
+		current := LayoutDetailed
+		if layouts.compact(chatID) {
+			current = LayoutCompact
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "⌨️ Your download keyboards are "+current+".\n\n"+
+			"Use /layout compact for just best video and MP3, or /layout detailed for every option."))
+	}
+}
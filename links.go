@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// urlPattern finds links in plain text when Telegram didn't mark them up
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// extractURL returns the first supported link in a message, or failing that the
+// first link of any kind, which might be a direct media file. Telegram's entities
+// are used when present since they're exact and include hyperlinks whose URL
+// isn't in the text at all; the regex covers the rest.
+func extractURL(text string, entities []tgbotapi.MessageEntity) string {
+	var candidates []string
+
+	encoded := utf16.Encode([]rune(text))
+	for _, entity := range entities {
+		switch entity.Type {
+		case "url":
+			// Entity offsets count UTF-16 code units, not bytes
+			if entity.Offset < 0 || entity.Offset+entity.Length > len(encoded) {
+				continue
+			}
+			candidates = append(candidates, string(utf16.Decode(encoded[entity.Offset:entity.Offset+entity.Length])))
+		case "text_link":
+			// The link is hidden behind the text, so it only exists in the entity
+			candidates = append(candidates, entity.URL)
+		}
+	}
+
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		// Trailing punctuation usually belongs to the sentence, not the link
+		candidates = append(candidates, strings.TrimRight(match, ".,;:!?)]}'"))
+	}
+
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, "http") {
+			candidate = "https://" + candidate
+		}
+		if isValidURL(candidate) || isShortURL(candidate) {
+			return candidate
+		}
+	}
+	// Any other link may still be a media file, which checkLink finds out
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, "http://") || strings.HasPrefix(candidate, "https://") {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// decodeStartPayload turns the payload of a t.me/<bot>?start=<payload> deep link
+// back into the link it carries. Payloads may only use A-Z, a-z, 0-9, _ and -,
+// so websites encode the link as URL-safe base64, with or without padding.
+func decodeStartPayload(payload string) (string, bool) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return "", false
+	}
+	link := strings.TrimSpace(string(decoded))
+	if !isValidURL(link) && !isShortURL(link) && !hasMediaExtension(link) {
+		return "", false
+	}
+	return link, true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultLiveDVRMaxMinutes caps how much of a live stream's aired portion is
+// captured when LIVE_DVR_MAX_MINUTES is unset
+const DefaultLiveDVRMaxMinutes = 60
+
+// liveDVRMaxMinutes is read from LIVE_DVR_MAX_MINUTES at startup
+var liveDVRMaxMinutes = loadLiveDVRMaxMinutes()
+
+func loadLiveDVRMaxMinutes() int {
+	minutes, err := strconv.Atoi(os.Getenv("LIVE_DVR_MAX_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return DefaultLiveDVRMaxMinutes
+	}
+	return minutes
+}
+
+// airedMinutes returns how many minutes of a live stream can be captured from
+// its start, capped at liveDVRMaxMinutes. yt-dlp reports the start of the DVR
+// window as release_timestamp; streams without one are not offered, since
+// there is no telling what is still available.
+func airedMinutes(meta *VideoMetadata) (int, bool) {
+	if meta == nil || !meta.IsLive || meta.ReleaseTimestamp <= 0 {
+		return 0, false
+	}
+	aired := time.Since(time.Unix(meta.ReleaseTimestamp, 0))
+	if aired < time.Minute {
+		return 0, false
+	}
+	minutes := int(aired / time.Minute)
+	if minutes > liveDVRMaxMinutes {
+		minutes = liveDVRMaxMinutes
+	}
+	return minutes, true
+}
+
+// createLiveRow offers the already-aired part of a live stream with DVR
+func createLiveRow(info Download) []tgbotapi.InlineKeyboardButton {
+	minutes, ok := airedMinutes(info.Meta)
+	if !ok {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+		fmt.Sprintf("🔴 Aired portion (%d min, may be large)", minutes), "live:aired"))
+}
+
+// liveArgs makes yt-dlp fetch a live stream from its start; excerptArgs then
+// stops it at the aired portion instead of following the stream
+func liveArgs(info Download) []string {
+	if !info.LiveFromStart {
+		return nil
+	}
+	return []string{"--live-from-start"}
+}
+
+// handleLiveDownload captures what a live stream has aired so far, up to
+// liveDVRMaxMinutes, in the best quality
+func handleLiveDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, statusMsgID int) {
+	minutes, ok := airedMinutes(info.Meta)
+	if !ok {
+		sendFailure(bot, chatID, "❌ This stream's aired portion isn't available to download.")
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"⚠️ Capturing %d minutes of the live stream. This may be a large file and take a while.", minutes)))
+
+	info.LiveFromStart = true
+	info.FirstMinutes = minutes
+	handleVideoDownload(bot, runner, chatID, info, "best", statusMsgID)
+}
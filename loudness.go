@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LoudnormFilter targets EBU R128 loudness, the same level streaming services use
+const LoudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// normalizeLoudness re-encodes an MP3 with ffmpeg's loudnorm filter and returns
+// the path of the normalized copy
+func normalizeLoudness(runner Runner, input string) (string, error) {
+	output := strings.TrimSuffix(input, ".mp3") + "_normalized.mp3"
+
+	_, err := runner.Run(context.Background(), "ffmpeg",
+		"-y",
+		"-v", "error",
+		"-i", input,
+		"-af", LoudnormFilter,
+		"-c:a", "libmp3lame",
+		"-q:a", "0",
+		output,
+	)
+	if err != nil {
+		return "", fmt.Errorf("loudness normalization failed: %w", err)
+	}
+	return output, nil
+}
@@ -1,15 +1,16 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -29,6 +30,44 @@ type Download struct {
 	Thumbnail string
 	Progress  int
 	IsAudio   bool
+	Meta      *VideoMetadata // nil when format discovery failed
+	// AudioLanguage is the audio track picked by the user, empty for the default
+	AudioLanguage string
+	// WithInfoJSON attaches yt-dlp's metadata sidecar to the download
+	WithInfoJSON bool
+	// Strategy is StrategyFast or StrategyBest, empty for the default
+	Strategy string
+	// FormatSelector is an exact -f selector given with /dl, used instead of
+	// the quality
+	FormatSelector string
+	// SkipSponsors cuts SponsorBlock segments out of YouTube videos
+	SkipSponsors bool
+	// WithDescription sends the video description after the media
+	WithDescription bool
+	// LimitFPS prefers video variants of at most 30fps
+	LimitFPS bool
+	// FileStamp names the temp files; a retry reuses it to resume (see fileStamp)
+	FileStamp int64
+	// Resume passes --continue so a retry picks up the failed attempt's partial files
+	Resume bool
+	// Compact shows the short keyboard, following the chat's /layout preference
+	Compact bool
+	// FirstMinutes, when set, downloads only the start of the video
+	FirstMinutes int
+	// JobID tags this link's log lines (see jobLog)
+	JobID string
+	// Downgrades counts automatic retries at a lower quality (see retryLowerQuality)
+	Downgrades int
+	// ShowVideo swaps a YouTube Music link's audio keyboard for the full one
+	ShowVideo bool
+	// FrameThumbnail uses a frame from the video as its preview (see thumbnailOffset)
+	FrameThumbnail bool
+	// KeepHDR sends HDR videos as they are instead of converting them to SDR
+	KeepHDR bool
+	// LiveFromStart captures a live stream from the start of its DVR window
+	LiveFromStart bool
+	// SourceMessageID is the user's message the link came in (see deleteSourceMessage)
+	SourceMessageID int
 }
 
 func main() {
@@ -44,132 +83,611 @@ func main() {
 	}
 
 	bot.Debug = true
+	loadAdminIDs()
+	if err := loadExtraArgs(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadInstagramSession(); err != nil {
+		log.Fatal(err)
+	}
+	cleanStalePartials()
+	checkFFmpeg()
+	if err := startFileServer(); err != nil {
+		log.Fatal(err)
+	}
+	if err := startDirectProxy(); err != nil {
+		log.Fatal(err)
+	}
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
+	// Stop taking new work on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := bot.GetUpdatesChan(u)
+	// Poll with backoff so the bot recovers from connectivity drops on its own
+	updates := pollUpdates(ctx, bot, u)
+
+	// All external commands go through the runner
+	runner := execRunner{}
 
-	// Map to store URL and download info by chat ID and message ID
-	urlCache := make(map[string]Download)
+	// Kill stuck child processes
+	startProcessSweeper(time.Minute)
 
-	// Welcome message when bot starts or /start command is received
-	welcomeMessage := `🚀 *Media Downloader*
+	// Forget format prompts nobody used in a day
+	startCacheSweeper(time.Hour)
 
-Send any link from these platforms:
-• YouTube
-• Instagram 
-• Facebook
-• TikTok
+	// Once the loop returns no new work can start, so the wait below is final
+	runUpdateLoop(ctx, bot, runner, updates)
+	// A second signal now kills the process straight away
+	stop()
 
-I'll download the video or audio for you!`
+	// Let running downloads finish, then kill whatever is left
+	log.Println("Shutting down, waiting for downloads in progress")
+	if !waitForDownloads(ShutdownGracePeriod) {
+		log.Printf("Downloads still running after %s, stopping child processes", ShutdownGracePeriod)
+	}
+	children.killAll()
+}
+
+// runUpdateLoop handles updates until the context is cancelled or the updates
+// channel is closed
+func runUpdateLoop(ctx context.Context, bot *tgbotapi.BotAPI, runner Runner, updates tgbotapi.UpdatesChannel) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			handleUpdate(bot, runner, update)
+		}
+	}
+}
 
-	for update := range updates {
-		if update.Message != nil {
-			// Handle /start command
-			if update.Message.Command() == "start" {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, welcomeMessage)
-				msg.ParseMode = "Markdown"
-				bot.Send(msg)
-				continue
+// handleUpdate dispatches a single message or button press
+func handleUpdate(bot *tgbotapi.BotAPI, runner Runner, update tgbotapi.Update) {
+	if update.Message != nil {
+		// A deep link to the bot can carry a link to download straight away
+		if update.Message.Command() == "start" && update.Message.CommandArguments() != "" {
+			if url, ok := decodeStartPayload(update.Message.CommandArguments()); ok {
+				stats.LinksReceived.Add(1)
+				goInFlight(func() { handleURL(bot, runner, update.Message.Chat.ID, update.Message.MessageID, url) })
+				return
 			}
+			log.Printf("Ignoring unrecognized /start payload %q", update.Message.CommandArguments())
+		}
+
+		// Handle /start and /help commands
+		if update.Message.Command() == "start" || update.Message.Command() == "help" {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, welcomeText())
+			msg.ParseMode = parseMode
+			bot.Send(msg)
+			return
+		}
+
+		// Handle /feedback command
+		if update.Message.Command() == "feedback" {
+			handleFeedback(bot, update.Message)
+			return
+		}
+
+		// Handle /dl command
+		if update.Message.Command() == "dl" {
+			goInFlight(func() { handleDownloadCommand(bot, runner, update.Message) })
+			return
+		}
+
+		// Handle /ping command
+		if update.Message.Command() == "ping" {
+			handlePing(bot, update.Message)
+			return
+		}
+
+		// Handle /stats command
+		if update.Message.Command() == "stats" {
+			handleStats(bot, update.Message)
+			return
+		}
+
+		// Handle /retry command
+		if update.Message.Command() == "retry" {
+			handleRetry(bot, runner, update.Message.Chat.ID, update.Message.From)
+			return
+		}
+
+		// Handle /check command
+		if update.Message.Command() == "check" {
+			goInFlight(func() { handleCheck(bot, runner, update.Message) })
+			return
+		}
+
+		// Handle /purge command
+		if update.Message.Command() == "purge" {
+			handlePurge(bot, update.Message)
+			return
+		}
+
+		// Handle /layout command
+		if update.Message.Command() == "layout" {
+			handleLayout(bot, update.Message)
+			return
+		}
+
+		// A "max 20MB" reply to a prompt picks the best quality under that size
+		if update.Message.ReplyToMessage != nil && isSizeTarget(update.Message.Text) {
+			handleSizeTarget(bot, runner, update.Message)
+			return
+		}
+
+		// Handle URLs, including ones in the caption of a forwarded post
+		text := update.Message.Text
+		entities := update.Message.Entities
+		isCaption := false
+		if strings.TrimSpace(text) == "" {
+			text = update.Message.Caption
+			entities = update.Message.CaptionEntities
+			isCaption = true
+		}
+		if strings.TrimSpace(text) == "" {
+			// Nothing but whitespace, or media without a caption
+			return
+		}
+
+		// Pull the link out of whatever else the message says
+		if url := extractURL(text, entities); url != "" {
+			if !linkDebounce.allow(update.Message.Chat.ID) {
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "⏳ One moment, please send links one at a time."))
+				return
+			}
+			stats.LinksReceived.Add(1)
+			goInFlight(func() { handleURL(bot, runner, update.Message.Chat.ID, update.Message.MessageID, url) })
+		} else if !isCaption {
+			bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID,
+				"📎 Please send a valid URL from "+enabledPlatformList()))
+		}
+	} else if update.CallbackQuery != nil {
+		// Handle button callbacks
+		callback := update.CallbackQuery
+
+		// The Retry button lives on failure messages, which aren't in the cache
+		if callback.Data == "retry:last" {
+			bot.Request(tgbotapi.NewCallback(callback.ID, "Retrying..."))
+			handleRetry(bot, runner, callback.Message.Chat.ID, callback.From)
+			return
+		}
+
+		cacheKey := getCacheKey(callback.Message.Chat.ID, callback.Message.MessageID)
+
+		// Ignore taps on a prompt whose download is already running
+		if urlCache.isActive(cacheKey) {
+			bot.Request(tgbotapi.NewCallback(callback.ID, "Already processing."))
+			return
+		}
+
+		if info, ok := urlCache.get(cacheKey); ok {
+			parts := strings.Split(callback.Data, ":")
+
+			if len(parts) == 2 {
+				format := parts[0]
+				quality := parts[1]
+
+				// Picking an audio language only updates the keyboard
+				if format == "lang" {
+					info.AudioLanguage = quality
+					urlCache.set(cacheKey, info)
+					bot.Request(tgbotapi.NewCallback(callback.ID, "Audio language: "+quality))
+					bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						createDownloadKeyboard(info),
+					))
+					return
+				}
+
+				// So is the download strategy
+				if format == "strategy" {
+					info.Strategy = quality
+					urlCache.set(cacheKey, info)
+					bot.Request(tgbotapi.NewCallback(callback.ID, "Strategy: "+quality))
+					bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						createDownloadKeyboard(info),
+					))
+					return
+				}
 
-			// Handle URLs
-			if update.Message.Text != "" {
-				url := update.Message.Text
-
-				// Check if the text is a URL
-				if isValidURL(url) {
-					// Extract info from URL
-					platform := detectPlatform(url)
-					info := Download{
-						URL:      url,
-						Platform: platform,
-						Progress: 0,
+				// The metadata sidecar, SponsorBlock, the description, 30fps, the
+				// preview frame and HDR are toggles as well
+				if format == "infojson" || format == "sponsorblock" || format == "description" || format == "fps30" || format == "thumbframe" || format == "hdr" {
+					switch format {
+					case "infojson":
+						info.WithInfoJSON = !info.WithInfoJSON
+					case "sponsorblock":
+						info.SkipSponsors = !info.SkipSponsors
+					case "description":
+						info.WithDescription = !info.WithDescription
+					case "fps30":
+						info.LimitFPS = !info.LimitFPS
+					case "thumbframe":
+						info.FrameThumbnail = !info.FrameThumbnail
+					case "hdr":
+						info.KeepHDR = !info.KeepHDR
 					}
+					urlCache.set(cacheKey, info)
+					bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+					bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						createDownloadKeyboard(info),
+					))
+					return
+				}
 
-					// Fetch video metadata
-					go func() {
-						title, thumbnail := getVideoInfo(url)
-						info.Title = title
-						info.Thumbnail = thumbnail
-
-						// Store URL and info for callback reference
-						cacheKey := getCacheKey(update.Message.Chat.ID, 0)
-						urlCache[cacheKey] = info
-
-						// Format platform icon
-						platformIcon := getPlatformIcon(platform)
-
-						// Send message with download options
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-							fmt.Sprintf("%s *%s*\n\n%s\n\nSelect download format:",
-								platformIcon,
-								platform,
-								truncateString(info.Title, 200)))
-						msg.ParseMode = "Markdown"
-						msg.ReplyMarkup = createDownloadKeyboard(platform)
-						sentMsg, _ := bot.Send(msg)
-
-						// Update cache key with the actual message ID
-						newCacheKey := getCacheKey(update.Message.Chat.ID, sentMsg.MessageID)
-						urlCache[newCacheKey] = info
-						delete(urlCache, cacheKey)
-
-						// Send thumbnail if available
-						if thumbnail != "" {
-							photoMsg := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileURL(thumbnail))
-							photoMsg.ReplyToMessageID = sentMsg.MessageID
-							bot.Send(photoMsg)
-						}
-					}()
-				} else {
-					bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID,
-						"📎 Please send a valid URL from YouTube, Instagram, Facebook, or TikTok"))
+				// Going back to the format list from a sub-menu or prompt
+				// Expand a compact or music keyboard for this message only
+				if format == "layout" || format == "music" {
+					info.Compact = false
+					info.ShowVideo = true
+					urlCache.set(cacheKey, info)
 				}
-			}
-		} else if update.CallbackQuery != nil {
-			// Handle button callbacks
-			callback := update.CallbackQuery
-			cacheKey := getCacheKey(callback.Message.Chat.ID, callback.Message.MessageID)
 
-			if info, ok := urlCache[cacheKey]; ok {
-				parts := strings.Split(callback.Data, ":")
+				if format == "menu" || format == "layout" || format == "music" {
+					bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+					editMsg := tgbotapi.NewEditMessageTextAndMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						downloadPromptText(info),
+						createDownloadKeyboard(info),
+					)
+					editMsg.ParseMode = parseMode
+					bot.Send(editMsg)
+					return
+				}
 
-				if len(parts) == 2 {
-					format := parts[0]
-					quality := parts[1]
+				// The "first N minutes" presets live in their own keyboard
+				if format == "first" && quality == "pick" {
+					bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+					bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						createExcerptKeyboard(info),
+					))
+					return
+				}
 
-					// Acknowledge the callback
-					bot.Request(tgbotapi.NewCallback(callback.ID, "Processing download..."))
+				// So do the audio sources
+				if format == "audiosrc" && quality == "pick" {
+					bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+					bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+						callback.Message.Chat.ID,
+						callback.Message.MessageID,
+						createAudioSourceKeyboard(info),
+					))
+					return
+				}
 
-					// Update info with audio flag
-					info.IsAudio = (format == "audio")
-					urlCache[cacheKey] = info
+				// Transcripts and subtitles may need a language chosen first
+				if (format == "transcript" || format == "subtitles") && quality == "pick" {
+					languages := transcriptLanguages(info.Meta)
+					if len(languages) == 0 {
+						bot.Request(tgbotapi.NewCallback(callback.ID, "No subtitles available"))
+						bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, "📄 This video has no transcript or subtitles available."))
+						return
+					}
+					if len(languages) > 1 {
+						bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+						bot.Send(tgbotapi.NewEditMessageReplyMarkup(
+							callback.Message.Chat.ID,
+							callback.Message.MessageID,
+							createTranscriptLanguageKeyboard(format, languages),
+						))
+						return
+					}
+					quality = languages[0]
+				}
 
-					// Edit message to show processing
-					progressMsg := fmt.Sprintf("⏳ *Processing %s download*\n\n%s\n\n0%% complete...",
-						quality, truncateString(info.Title, 150))
+				// Turn "best under limit" into a concrete resolution
+				skipSizeCheck := false
+				if format == "video" && quality == "fit" {
+					fitQuality, estimate, fits, ok := bestUnderLimit(info)
+					if !ok {
+						bot.Request(tgbotapi.NewCallback(callback.ID, "File sizes are unknown, please pick a quality"))
+						return
+					}
+					if !fits {
+						bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, fmt.Sprintf(
+							"⚠️ No quality fits under %d MB. Downloading the smallest one (%s, about %.0f MB), which may still be too large.",
+							MaxFileSize/1048576, fitQuality, float64(estimate)/1048576)))
+					}
+					quality = fitQuality
+					skipSizeCheck = true
+				}
 
-					editMsg := tgbotapi.NewEditMessageText(
+				if format == "confirm" {
+					// The user accepted the size warning, so skip the check
+					var ok bool
+					if format, quality, ok = parseConfirmation(quality); !ok {
+						return
+					}
+				} else if estimate := estimateDownloadSize(info, format, quality); !skipSizeCheck && needsSizeConfirmation(estimate) {
+					// Ask before starting a download that will likely be rejected
+					bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+					editMsg := tgbotapi.NewEditMessageTextAndMarkup(
 						callback.Message.Chat.ID,
 						callback.Message.MessageID,
-						progressMsg,
+						sizeConfirmationText(info, quality, estimate),
+						createSizeConfirmKeyboard(format, quality),
 					)
-					editMsg.ParseMode = "Markdown"
-					editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{}
-					statusMsg, _ := bot.Send(editMsg)
-
-					if format == "video" {
-						go handleVideoDownload(bot, callback.Message.Chat.ID, info, quality, statusMsg.MessageID)
-					} else if format == "audio" {
-						go handleAudioDownload(bot, callback.Message.Chat.ID, info, statusMsg.MessageID)
+					editMsg.ParseMode = parseMode
+					bot.Send(editMsg)
+					return
+				}
+
+				if !urlCache.tryStart(cacheKey) {
+					bot.Request(tgbotapi.NewCallback(callback.ID, "Already processing."))
+					return
+				}
+
+				// Enforce the daily quota
+				if ok, resetAt := quotas.consume(callback.From.ID); !ok {
+					urlCache.finish(cacheKey)
+					bot.Request(tgbotapi.NewCallback(callback.ID, "Daily quota reached"))
+					bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, quotaExceededText(resetAt)))
+					return
+				}
+
+				// Acknowledge the callback
+				bot.Request(tgbotapi.NewCallback(callback.ID, "Processing download..."))
+
+				// Update info with audio flag
+				info.IsAudio = (format == "audio")
+				urlCache.set(cacheKey, info)
+
+				// Edit message to show processing
+				label := quality
+				switch format {
+				case "video", "both":
+					label = strategyLabel(info, quality)
+				case "first":
+					label = fmt.Sprintf("first %s minutes", quality)
+				case "live":
+					label = "aired portion"
+				case "audio":
+					if formatID, ok := audioSourceID(quality); ok {
+						label = "audio source " + formatID
 					}
 				}
+				progressMsg := buildMessage(info.Title, func(title string) string {
+					return fmt.Sprintf("⏳ "+bold("Processing %s download")+"\n\n%s\n\n0%% complete...", escapeText(label), title)
+				})
+
+				editMsg := tgbotapi.NewEditMessageText(
+					callback.Message.Chat.ID,
+					callback.Message.MessageID,
+					progressMsg,
+				)
+				editMsg.ParseMode = parseMode
+				editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{}
+				sendWithRetry(bot, editMsg)
+
+				// The edited prompt doubles as the status message
+				startDownload(bot, runner, callback.Message.Chat.ID, info, format, quality, callback.Message.MessageID)
+			}
+		}
+	}
+}
+
+// handleURL resolves a link, fetches its metadata and replies with the download options
+func handleURL(bot *tgbotapi.BotAPI, runner Runner, chatID int64, sourceMsgID int, url string) {
+	// Let the user know right away that the link was received
+	placeholder, err := sendWithRetry(bot, tgbotapi.NewMessage(chatID, "🔍 Fetching info..."))
+	if err != nil {
+		log.Println("Failed to send placeholder:", err)
+		return
+	}
+
+	// replaceWith turns the placeholder into a plain reply
+	replaceWith := func(text string) {
+		sendWithRetry(bot, tgbotapi.NewEditMessageText(chatID, placeholder.MessageID, text))
+	}
+
+	url, problem := checkLink(url)
+	if problem != "" {
+		replaceWith(problem)
+		return
+	}
+
+	// Extract info from URL
+	info := Download{
+		URL:      url,
+		Platform: detectPlatform(url),
+		Progress: 0,
+		Compact:  layouts.compact(chatID),
+		JobID:    newJobID(),
+		// Kept so the link message can be removed once the download starts
+		SourceMessageID: sourceMsgID,
+	}
+	jobLog(info).Printf("Chat %d sent %s", chatID, url)
+
+	// Fetch video metadata
+	meta := getVideoInfo(runner, url)
+	info.Meta = meta
+	info.Title = "Title unavailable"
+	if meta != nil {
+		info.Title = meta.Title
+		info.Thumbnail = meta.Thumbnail
+	}
+	keyboard := createDownloadKeyboard(info)
+	if isInstagramImagePost(info) || isInstagramHighlight(info) {
+		keyboard = createImageKeyboard()
+	}
+
+	// The thumbnail goes out before the options, so the message with the buttons
+	// is always the latest one and nothing lands after it once the user taps
+	if info.Thumbnail != "" {
+		if _, err := bot.Send(tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(info.Thumbnail))); err != nil {
+			log.Println("Failed to send thumbnail:", err)
+		} else {
+			msg := tgbotapi.NewMessage(chatID, downloadPromptText(info))
+			msg.ParseMode = parseMode
+			msg.ReplyMarkup = keyboard
+			prompt, err := sendWithRetry(bot, msg)
+			if err == nil {
+				// Store URL and info for callback reference
+				urlCache.set(getCacheKey(chatID, prompt.MessageID), info)
+				bot.Request(tgbotapi.NewDeleteMessage(chatID, placeholder.MessageID))
+				return
 			}
+			log.Println("Failed to send download options:", err)
+		}
+	}
+
+	// Store URL and info for callback reference
+	urlCache.set(getCacheKey(chatID, placeholder.MessageID), info)
+
+	// Turn the placeholder into the message with download options
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, placeholder.MessageID, downloadPromptText(info), keyboard)
+	editMsg.ParseMode = parseMode
+	if _, err := sendWithRetry(bot, editMsg); err != nil {
+		log.Println("Failed to send download options:", err)
+	}
+}
+
+// checkLink expands short links and makes sure the result is something the bot
+// may download. It returns the final URL, or a message explaining the problem.
+func checkLink(url string) (string, string) {
+	// Resolve short links so the real platform can be detected
+	if isShortURL(url) {
+		if expanded, err := expandURL(url); err != nil {
+			log.Printf("Failed to expand short URL %s: %v", url, err)
+		} else {
+			url = expanded
 		}
 	}
+
+	if !isValidURL(url) {
+		// A plain link to a media file is downloaded as is, through the proxy
+		// that keeps it off private addresses
+		if isPlatformEnabled(DirectPlatform) && directProxyURL != "" && isPublicHost(url) &&
+			(hasMediaExtension(url) || probeDirectMedia(url)) {
+			return url, ""
+		}
+		return url, "📎 Please send a valid URL from " + enabledPlatformList()
+	}
+
+	platform := detectPlatform(url)
+	if !isPlatformEnabled(platform) {
+		return url, fmt.Sprintf("🚫 %s downloads are currently disabled. Supported: %s", platform, enabledPlatformList())
+	}
+	if platform == "YouTube" && !isYouTubeVideoURL(url) && !isYouTubeMusicAlbum(url) {
+		return url, nonVideoYouTubeMessage
+	}
+	// Instagram serves highlights to logged-in users only
+	if platform == "Instagram" && instagramKind(url) == InstagramHighlight && instagramCookieFile == "" {
+		return url, highlightLoginMessage
+	}
+	if !breakers.allow(platform) {
+		return url, fmt.Sprintf("⚠️ %s downloads are temporarily unavailable. Please try again in a few minutes.", platform)
+	}
+	return url, ""
+}
+
+// downloadPromptText is the text above the format keyboard
+func downloadPromptText(info Download) string {
+	// Format platform icon
+	platformIcon := getPlatformIcon(info.Platform)
+
+	// Replying with a size only works when the formats list their sizes
+	hint := ""
+	if _, _, _, ok := bestUnderLimit(info); ok {
+		hint = "\n\nOr reply with a size, e.g. max 20MB"
+	}
+
+	return buildMessage(info.Title, func(title string) string {
+		return fmt.Sprintf("%s "+bold("%s")+"\n\n%s\n\nSelect download format:%s",
+			platformIcon, info.Platform, title, hint)
+	})
+}
+
+// startDownload remembers the attempt for /retry and launches the matching handler
+func startDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, format, quality string, statusMsgID int) {
+	// The status message is the prompt itself when started from a button
+	startDownloadUnder(bot, runner, chatID, info, format, quality, statusMsgID, getCacheKey(chatID, statusMsgID))
+}
+
+// startDownloadUnder is startDownload for a download marked as running under
+// key, which the caller has claimed with urlCache.tryStart
+func startDownloadUnder(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, format, quality string, statusMsgID int, key string) {
+	if info.FileStamp == 0 {
+		info.FileStamp = time.Now().UnixNano()
+	}
+	if info.JobID == "" {
+		info.JobID = newJobID()
+	}
+	jobLog(info).Printf("Starting %s download (%s) for chat %d", format, quality, chatID)
+	deleteSourceMessage(bot, chatID, info)
+	// The message is gone now, so a retry shouldn't try to delete it again
+	info.SourceMessageID = 0
+	recordAttempt(chatID, Attempt{Info: info, Format: format, Quality: quality, Key: key})
+
+	// Callers are the update loop itself or a handler it counted in inFlight,
+	// so this Add can't come after the shutdown started waiting
+	goInFlight(func() {
+		stats.DownloadsStarted.Add(1)
+		defer urlCache.finish(key)
+
+		// Wait for a free slot, showing the queue position meanwhile
+		if downloads.acquire(queuePositionUpdater(bot, chatID, statusMsgID, info.Title)) {
+			editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+				buildMessage(info.Title, func(title string) string {
+					return fmt.Sprintf("⏳ "+bold("Processing %s download")+"\n\n%s\n\n0%% complete...", escapeText(quality), title)
+				}),
+			)
+			editMsg.ParseMode = parseMode
+			bot.Send(editMsg)
+		}
+		defer downloads.release()
+
+		started := time.Now()
+		defer func() { downloadDurations.record(time.Since(started)) }()
+
+		stats.ActiveDownloads.Add(1)
+		defer stats.ActiveDownloads.Add(-1)
+
+		switch format {
+		case "video":
+			handleVideoDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "audio":
+			if quality == "chapters" {
+				handleChapterAudioDownload(bot, runner, chatID, info, statusMsgID)
+			} else {
+				handleAudioDownload(bot, runner, chatID, info, quality, statusMsgID)
+			}
+		case "image":
+			handleImageDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "transcript":
+			handleTranscriptDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "subtitles":
+			handleSubtitleDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "both":
+			handleVideoAndAudioDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "playlist":
+			if quality == "audiozip" {
+				handlePlaylistAudioZip(bot, runner, chatID, info, statusMsgID)
+			} else {
+				handlePlaylistDownload(bot, runner, chatID, info, statusMsgID)
+			}
+		case "first":
+			handleExcerptDownload(bot, runner, chatID, info, quality, statusMsgID)
+		case "live":
+			handleLiveDownload(bot, runner, chatID, info, statusMsgID)
+		}
+	})
 }
 
 func getCacheKey(chatID int64, messageID int) string {
@@ -184,7 +702,8 @@ func isValidURL(url string) bool {
 			strings.Contains(url, "instagram.com") ||
 			strings.Contains(url, "facebook.com") ||
 			strings.Contains(url, "fb.com") ||
-			strings.Contains(url, "tiktok.com"))
+			strings.Contains(url, "tiktok.com") ||
+			strings.Contains(url, "loom.com"))
 }
 
 func detectPlatform(url string) string {
@@ -199,6 +718,10 @@ func detectPlatform(url string) string {
 		return "Facebook"
 	case strings.Contains(lowerURL, "tiktok.com") || strings.Contains(lowerURL, "vm.tiktok.com"):
 		return "TikTok"
+	case strings.Contains(lowerURL, "loom.com"):
+		return "Loom"
+	case isDirectMediaURL(url):
+		return DirectPlatform
 	default:
 		return "Unknown"
 	}
@@ -214,33 +737,145 @@ func getPlatformIcon(platform string) string {
 		return "👤"
 	case "TikTok":
 		return "🎵"
+	case "Loom":
+		return "🖥"
 	default:
 		return "🔗"
 	}
 }
 
-func getVideoInfo(url string) (title string, thumbnail string) {
-	// Get video title and thumbnail using yt-dlp
-	cmd := exec.Command("yt-dlp", "--get-title", "--get-thumbnail", "--no-playlist", url)
-	output, err := cmd.Output()
+// getVideoInfo fetches the video's metadata, including its available formats.
+// It returns nil if yt-dlp couldn't describe the video.
+func getVideoInfo(runner Runner, url string) *VideoMetadata {
+	// Wait for a free slot first, so time spent queued doesn't count against
+	// the timeout
+	metadataSlots <- struct{}{}
+	defer func() { <-metadataSlots }()
+
+	// Don't let a slow site keep the user waiting for the keyboard forever
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
 
+	meta, err := fetchMetadata(ctx, runner, url)
 	if err != nil {
-		log.Printf("Error getting video info: %v", err)
-		return "Unknown Title", ""
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("Timed out after %s getting video info for %s", metadataTimeout, url)
+		} else {
+			log.Printf("Error getting video info: %v", err)
+		}
+		return nil
 	}
+	return meta
+}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) >= 1 {
-		title = lines[0]
+// createDownloadKeyboard builds the full keyboard for a download: the format
+// buttons followed by any extra pickers the video calls for
+func createDownloadKeyboard(info Download) tgbotapi.InlineKeyboardMarkup {
+	if isYouTubeMusicURL(info.URL) && !info.ShowVideo {
+		return createMusicKeyboard(info)
 	}
-	if len(lines) >= 2 {
-		thumbnail = lines[1]
+	if info.Compact {
+		return createCompactKeyboard(info)
 	}
-
-	return
+	keyboard := createFormatKeyboard(info.Platform, info.Meta)
+	if info.Platform == "TikTok" {
+		// The watermark-free version is what most TikTok users are after, so it goes first
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📹 No watermark", "video:nowm"),
+			),
+		}, keyboard.InlineKeyboard...)
+	}
+	if _, _, _, ok := bestUnderLimit(info); ok {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎯 Best under limit", "video:fit"),
+			),
+		)
+	}
+	smallest := "📶 Smallest file"
+	if estimate := estimateDownloadSize(info, "video", "smallest"); estimate > 0 {
+		smallest += fmt.Sprintf(" (~%.1f MB)", float64(estimate)/1048576)
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(smallest, "video:smallest"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📹+🎵 Both", "both:best"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎞 Original (no remux)", "video:original"),
+		),
+	)
+	if info.Platform == "YouTube" {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📄 Transcript", "transcript:pick"),
+			),
+		)
+	}
+	if row := createSubtitlesRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎵 Original audio", "audio:original"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎚 MP3 (normalized volume)", "audio:loudnorm"),
+		),
+	)
+	if row := createAudioSourceRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if offersVoice(info.Meta) {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎙 As voice", "audio:voice"),
+			),
+		)
+	}
+	if hasChapters(info.Meta) {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📑 Audio split by chapters", "audio:chapters"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📚 Audio with chapter markers", "audio:embedchapters"),
+			),
+		)
+	}
+	if row := createLiveRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createExcerptRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createPlaylistRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createFPSRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createHDRRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createSponsorBlockRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if row := createDescriptionRow(info); row != nil {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	if info.Thumbnail != "" {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, createThumbnailRow(info))
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, createStrategyRow(info), createInfoJSONRow(info))
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, createLanguageRows(info)...)
+	return keyboard
 }
 
-func createDownloadKeyboard(platform string) tgbotapi.InlineKeyboardMarkup {
+func createFormatKeyboard(platform string, meta *VideoMetadata) tgbotapi.InlineKeyboardMarkup {
 	switch platform {
 	case "YouTube":
 		return tgbotapi.NewInlineKeyboardMarkup(
@@ -256,115 +891,146 @@ func createDownloadKeyboard(platform string) tgbotapi.InlineKeyboardMarkup {
 			),
 		)
 	case "Instagram", "Facebook", "TikTok":
+		// Offer the resolutions the video actually comes in when we know them
+		if heights := availableHeights(meta); len(heights) > 0 {
+			return createResolutionKeyboard(meta, heights)
+		}
 		return tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("📹 Medium Quality Only", "video:medium"),
-			),
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🔊 Audio Only", "audio:mp3"),
-			),
-		)
-	default:
-		return tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📹 Medium Quality", "video:medium"),
 				tgbotapi.NewInlineKeyboardButtonData("📹 Best Quality", "video:best"),
 			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔊 Audio Only", "audio:mp3"),
 			),
 		)
+	case "Loom":
+		// Screen recordings come in a handful of renditions; the best is the default
+		if heights := availableHeights(meta); len(heights) > 0 {
+			return createResolutionKeyboard(meta, heights)
+		}
+		return createGenericKeyboard(meta)
+	default:
+		// Any platform without a tuned keyboard, including ones newly added to
+		// detectPlatform, gets the generic one
+		return createGenericKeyboard(meta)
 	}
 }
 
-func handleVideoDownload(bot *tgbotapi.BotAPI, chatID int64, info Download, quality string, statusMsgID int) {
-	// Create unique filename with timestamp
-	timestamp := time.Now().UnixNano()
-	videoOutput := fmt.Sprintf("video_%d.%%(ext)s", timestamp)
-	// progressFile := fmt.Sprintf("progress_%d.txt", timestamp)
-
-	// Set format code based on platform and quality
-	var formatCode string
+// createGenericKeyboard works for any site yt-dlp supports: the best video and
+// audio, plus the resolutions the video comes in when the formats list them.
+// resolveFormatCode has generic selectors for all of these.
+func createGenericKeyboard(meta *VideoMetadata) tgbotapi.InlineKeyboardMarkup {
+	best := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📹 Best Quality", "video:best"),
+	)
+	if heights := availableHeights(meta); len(heights) > 0 {
+		keyboard := createResolutionKeyboard(meta, heights)
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{best}, keyboard.InlineKeyboard...)
+		return keyboard
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		best,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔊 Audio Only", "audio:mp3"),
+		),
+	)
+}
 
-	switch {
-	case info.Platform == "YouTube":
-		switch quality {
-		case "360p":
-			formatCode = "18/bestvideo[height<=360]+bestaudio/best[height<=360]"
-		case "480p":
-			formatCode = "135+bestaudio/bestvideo[height<=480]+bestaudio/best[height<=480]"
-		case "720p":
-			formatCode = "22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]"
-		default:
-			formatCode = "best"
+// createResolutionKeyboard builds a keyboard with one button per available
+// height, each labelled with its estimated size when the formats tell us
+func createResolutionKeyboard(meta *VideoMetadata, heights []int) tgbotapi.InlineKeyboardMarkup {
+	perRow := 3
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, height := range heights {
+		quality := fmt.Sprintf("%dp", height)
+		label := "📹 " + quality
+		if estimate := estimateDownloadSize(Download{Meta: meta}, "video", quality); estimate > 0 {
+			// Sizes make the labels longer, so fewer fit on a row
+			label += fmt.Sprintf(" (~%.1f MB)", float64(estimate)/1048576)
+			perRow = 2
 		}
-	case info.Platform == "Instagram" || info.Platform == "Facebook" || info.Platform == "TikTok":
-		switch quality {
-		case "medium":
-			formatCode = "worst[ext=mp4]/worst"
-		default:
-			formatCode = "best[ext=mp4]/best"
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "video:"+quality))
+		if len(row) >= perRow {
+			rows = append(rows, row)
+			row = nil
 		}
-	default:
-		formatCode = "best"
-	}
-
-	// Build arguments for yt-dlp
-	ytdlpArgs := []string{
-		"-f", formatCode,
-		"--remux-video", "mp4", // Add this line to ensure proper container format
-		"-o", videoOutput,
-		"--newline",
-		"--progress-template", "%(progress.downloaded_bytes)s/%(progress.total_bytes)s",
-		"--no-playlist",
 	}
-
-	// Add cookies for platforms that need authentication
-	switch info.Platform {
-	case "Instagram", "Facebook":
-		ytdlpArgs = append(ytdlpArgs, "--no-check-certificate")
+	if len(row) > 0 {
+		rows = append(rows, row)
 	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔊 Audio Only", "audio:mp3"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
 
-	// Add the URL as the last argument
-	ytdlpArgs = append(ytdlpArgs, info.URL)
-
-	// Create command
-	cmd := exec.Command("yt-dlp", ytdlpArgs...)
-
-	// Set up progress tracking
-	progressPipe, _ := cmd.StderrPipe()
+func handleVideoDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, quality string, statusMsgID int) {
+	// Create unique filename with instance ID and timestamp
+	timestamp := fileStamp(info)
+	videoOutput := filePrefix("video", timestamp) + ".%(ext)s"
+	// progressFile := fmt.Sprintf("progress_%d.txt", timestamp)
 
-	// Start the command
-	err := cmd.Start()
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to start download process."))
-		log.Println("Command start error:", err)
-		return
+	// Build arguments for yt-dlp
+	ytdlpArgs := buildVideoArgs(info, quality, videoOutput)
+
+	// Fetch the platform's thumbnail while the video downloads, so the upload
+	// doesn't wait on it. Frame thumbnails need the video and are made later.
+	// The name has its own timestamp since a downgrade retry reuses the video's.
+	thumbFile := filePrefix("thumb", time.Now().UnixNano()) + ".jpg"
+	var thumbWG sync.WaitGroup
+	var thumbErr error
+	if info.Thumbnail != "" && !info.FrameThumbnail {
+		thumbWG.Add(1)
+		go func() {
+			defer thumbWG.Done()
+			thumbErr = prepareThumbnail(runner, info.Thumbnail, thumbFile, 0)
+		}()
 	}
+	defer func(path string) {
+		thumbWG.Wait()
+		os.Remove(path)
+	}(thumbFile)
 
-	// Read progress updates
-	go trackProgress(bot, chatID, statusMsgID, progressPipe, info.Title, quality)
-
-	// Wait for command to complete
-	err = cmd.Wait()
+	// Run yt-dlp, trying alternate YouTube player clients when needed
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, strategyLabel(info, quality))
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to download video."))
-		log.Println("Download error:", err)
+		// Turn a missing format into a fresh choice instead of a dead end
+		if isFormatUnavailable(output) {
+			offerAvailableFormats(bot, runner, chatID, statusMsgID, info)
+			return
+		}
+		// ffmpeg disappeared since startup; try again with a single-file format
+		if isFFmpegMissing(output) && hasFFmpeg() {
+			markFFmpegMissing(bot, info)
+			handleVideoDownload(bot, runner, chatID, info, quality, statusMsgID)
+			return
+		}
+		reportDownloadFailure(bot, chatID, info, "video download", err, output)
 		return
 	}
 
 	// Find downloaded file
-	videoFiles, _ := filepath.Glob(fmt.Sprintf("video_%d.*", timestamp))
-	if len(videoFiles) == 0 {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ No video file found after download completed."))
+	videoFile, ok := findOutputFile(filePrefix("video", timestamp))
+	if !ok {
+		sendFailure(bot, chatID, "❌ No video file found after download completed.")
 		return
 	}
-	videoFile := videoFiles[0]
+	metaFile := infoJSONFile(filePrefix("video", timestamp))
+	defer os.Remove(metaFile)
 	defer os.Remove(videoFile)
 
+	if err := runPostDownloadHook(runner, videoFile); err != nil {
+		jobLog(info).Println(err)
+		sendFailure(bot, chatID, hookRejectedMessage)
+		return
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(videoFile)
 	if err != nil {
-		log.Println("Failed to get file info:", err)
+		jobLog(info).Println("Failed to get file info:", err)
 	}
 
 	// Convert bytes to MB
@@ -374,99 +1040,362 @@ func handleVideoDownload(bot *tgbotapi.BotAPI, chatID int64, info Download, qual
 	editMsg := tgbotapi.NewEditMessageText(
 		chatID,
 		statusMsgID,
-		fmt.Sprintf("✅ *Download Complete!*\n\n%s\n\nUploading to Telegram...",
-			truncateString(info.Title, 150)),
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("✅ "+bold("Download Complete!")+"\n\n%s\n\nUploading to Telegram...", title)
+		}),
 	)
-	editMsg.ParseMode = "Markdown"
+	editMsg.ParseMode = parseMode
 	bot.Send(editMsg)
 
 	// Check if file is too large
 	if fileInfo.Size() > MaxFileSize {
+		if retryLowerQuality(bot, runner, chatID, info, quality, videoFile, statusMsgID) {
+			return
+		}
+		if sendOversized(bot, chatID, info, videoFile, fileSizeMB) {
+			return
+		}
 		bot.Send(tgbotapi.NewMessage(chatID,
 			fmt.Sprintf("⚠️ Video file (%.1f MB) exceeds Telegram's limit. Try a lower quality option.", fileSizeMB)))
 		return
 	}
 
-	// Format caption
-	caption := fmt.Sprintf("📹 *%s* - %s\n▫️ Quality: %s\n▫️ Size: %.1f MB",
-		info.Platform,
-		truncateString(info.Title, 100),
-		quality,
-		fileSizeMB)
+	// Make sure the file really is a playable video before sending it as one.
+	// Without ffprobe there's nothing to check with, so the single-file format
+	// yt-dlp picked is trusted.
+	media := unprobedVideo(info.Meta)
+	if hasFFprobe() {
+		media, err = probeMedia(runner, videoFile)
+		if err != nil {
+			jobLog(info).Println("Failed to probe video file:", err)
+		}
+	}
+
+	// useProcessed swaps in a re-encoded copy of the video, reporting false if
+	// the copy no longer fits within Telegram's limit
+	useProcessed := func(path string) bool {
+		videoFile = path
+		if fixed, err := probeMedia(runner, path); err == nil {
+			media = fixed
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return true
+		}
+		fileSizeMB = float64(stat.Size()) / 1048576
+		if stat.Size() > MaxFileSize {
+			if retryLowerQuality(bot, runner, chatID, info, quality, path, statusMsgID) {
+				return false
+			}
+			if sendOversized(bot, chatID, info, path, fileSizeMB) {
+				return false
+			}
+			bot.Send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("⚠️ Video file (%.1f MB) exceeds Telegram's limit. Try a lower quality option.", fileSizeMB)))
+			return false
+		}
+		return true
+	}
+
+	// Sideways phone videos get their rotation applied to the frames
+	if media.HasVideo && media.Rotation != 0 && hasFFmpeg() {
+		if upright, err := applyRotation(runner, videoFile); err != nil {
+			jobLog(info).Println("Failed to fix video rotation:", err)
+		} else {
+			defer os.Remove(upright)
+			if !useProcessed(upright) {
+				return
+			}
+		}
+	}
+
+	// HDR that couldn't be avoided is tone-mapped, unless the user wants it kept
+	if media.HasVideo && media.HDR && !info.KeepHDR && hasFFmpeg() {
+		if sdr, err := toneMapToSDR(runner, videoFile); err != nil {
+			jobLog(info).Println("Failed to tone-map HDR video:", err)
+		} else {
+			defer os.Remove(sdr)
+			if !useProcessed(sdr) {
+				return
+			}
+		}
+	}
+
+	// Brand the video when the operator configured a watermark; audio-only
+	// files are left alone
+	if media.HasVideo && watermarkEnabled() && hasFFmpeg() {
+		if branded, err := applyWatermark(runner, videoFile); err != nil {
+			jobLog(info).Println("Failed to apply watermark:", err)
+		} else {
+			defer os.Remove(branded)
+			if !useProcessed(branded) {
+				return
+			}
+		}
+	}
+
+	switch {
+	case media.HasVideo:
+		// Format caption
+		// Without remuxing the container may be one some Telegram clients can't play
+		note := uploadDateNote(info.Meta) + sponsorBlockNote(info, output) + excerptNote(info)
+		if ext := strings.ToLower(filepath.Ext(videoFile)); ext != ".mp4" {
+			note += fmt.Sprintf("\n⚠️ Original %s file, may not play in all Telegram clients", strings.TrimPrefix(ext, "."))
+		}
+		// Large videos go as files so Telegram's preview generation can't sink them
+		asDocument := sendAsDocument(videoFile)
+		if asDocument {
+			note += "\n▫️ Sent as a file for reliable delivery"
+		}
+
+		caption := buildCaption(info.Title, func(title string) string {
+			return fmt.Sprintf("📹 "+bold("%s")+" - %s\n▫️ Quality: %s\n▫️ Resolution: %s\n▫️ Duration: %s\n▫️ Size: %.1f MB%s",
+				info.Platform,
+				title,
+				escapeText(quality),
+				formatResolution(media),
+				formatDuration(media.Duration),
+				fileSizeMB,
+				note)
+		})
+
+		// Use the platform's thumbnail fetched during the download, or take a
+		// frame from the video when there is none or the user asked for one
+		thumbWG.Wait()
+		if info.Thumbnail == "" || info.FrameThumbnail {
+			thumbErr = prepareThumbnail(runner, videoFile, thumbFile, thumbnailOffset(media.Duration))
+		}
+		if thumbErr != nil {
+			jobLog(info).Println("Failed to prepare thumbnail:", thumbErr)
+			thumbFile = ""
+		}
 
-	// Send video
-	video := tgbotapi.NewVideo(chatID, tgbotapi.FilePath(videoFile))
-	video.Caption = caption
-	video.ParseMode = "Markdown"
-	if _, err := bot.Send(video); err != nil {
-		log.Println("Failed to send video:", err)
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to send video. File might be too large for Telegram."))
+		// Send video, reporting upload progress on the status message
+		upload, file, err := openUpload(bot, chatID, statusMsgID, videoFile, info)
+		if err != nil {
+			jobLog(info).Println("Failed to open video file:", err)
+			sendFailure(bot, chatID, "❌ Failed to send video.")
+			return
+		}
+		defer file.Close()
+
+		err = retryTransient(func() error {
+			_, err := sendVideoFile(bot, chatID, upload, caption, media, thumbFile, asDocument)
+			return err
+		}, file)
+		if isFileTooBig(err) {
+			file.Close()
+			handleRejectedAsTooBig(bot, runner, chatID, info, "video", quality, videoFile, fileSizeMB, statusMsgID)
+		} else if err != nil {
+			jobLog(info).Println("Failed to send video:", err)
+			sendFailure(bot, chatID, "❌ Failed to send video.")
+		} else {
+			downloadSucceeded(chatID)
+			sendExtras(bot, chatID, info, metaFile)
+		}
+	case media.HasAudio:
+		// Remux left us with an audio-only file, so send what we have
+		caption := buildCaption(info.Title, func(title string) string {
+			return fmt.Sprintf("🎵 "+bold("%s")+" - %s\n▫️ No video stream was available\n▫️ Size: %.1f MB",
+				info.Platform, title, fileSizeMB)
+		})
+
+		upload, file, err := openUpload(bot, chatID, statusMsgID, videoFile, info)
+		if err != nil {
+			jobLog(info).Println("Failed to open audio file:", err)
+			sendFailure(bot, chatID, "❌ Failed to send audio.")
+			return
+		}
+		defer file.Close()
+
+		audio := tgbotapi.NewAudio(chatID, upload)
+		audio.Caption = caption
+		audio.ParseMode = parseMode
+		audio.Title = info.Title
+		audio.Duration = media.Duration
+		if _, err := sendWithRetry(bot, audio, file); isFileTooBig(err) {
+			file.Close()
+			handleRejectedAsTooBig(bot, runner, chatID, info, "audio", quality, videoFile, fileSizeMB, statusMsgID)
+		} else if err != nil {
+			jobLog(info).Println("Failed to send audio:", err)
+			sendFailure(bot, chatID, "❌ Failed to send audio.")
+		} else {
+			downloadSucceeded(chatID)
+			sendExtras(bot, chatID, info, metaFile)
+		}
+	default:
+		// Unknown or broken file, let the user have it as a plain document
+		caption := buildCaption(info.Title, func(title string) string {
+			return fmt.Sprintf("📄 "+bold("%s")+" - %s\n▫️ The file could not be verified as a video\n▫️ Size: %.1f MB",
+				info.Platform, title, fileSizeMB)
+		})
+
+		upload, file, err := openUpload(bot, chatID, statusMsgID, videoFile, info)
+		if err != nil {
+			jobLog(info).Println("Failed to open file:", err)
+			sendFailure(bot, chatID, "❌ Failed to send file.")
+			return
+		}
+		defer file.Close()
+
+		document := tgbotapi.NewDocument(chatID, upload)
+		document.Caption = caption
+		document.ParseMode = parseMode
+		if _, err := sendWithRetry(bot, document, file); err != nil {
+			jobLog(info).Println("Failed to send document:", err)
+			sendFailure(bot, chatID, "❌ Failed to send file. File might be too large for Telegram.")
+		} else {
+			downloadSucceeded(chatID)
+			sendExtras(bot, chatID, info, metaFile)
+		}
 	}
 }
 
-func handleAudioDownload(bot *tgbotapi.BotAPI, chatID int64, info Download, statusMsgID int) {
-	// Create unique filename with timestamp
-	timestamp := time.Now().UnixNano()
-	audioOutput := fmt.Sprintf("audio_%d.%%(ext)s", timestamp)
+// buildVideoArgs returns the yt-dlp arguments (without the URL) for a video download
+func buildVideoArgs(info Download, quality, output string) []string {
+	// Set format code based on platform and quality. A specific audio language
+	// needs a separate audio stream, so it always uses the merging strategy.
+	formatCode := resolveFormatCode(info.Platform, quality)
+	_, overridden := formatOverride(info.Platform, quality)
+	if qualityStrategy(info, quality) == StrategyFast && info.AudioLanguage == "" && !overridden {
+		if progressive, ok := progressiveFormatCode(quality); ok {
+			formatCode = progressive
+		}
+	}
+	formatCode = withAudioLanguage(formatCode, info.AudioLanguage)
+	if info.LimitFPS {
+		formatCode = withFPSLimit(formatCode)
+	}
+	if !info.KeepHDR && hasHDR(info.Meta) {
+		formatCode = withSDRPreference(formatCode)
+	}
+	if info.FormatSelector != "" {
+		// The user asked for exactly this
+		formatCode = info.FormatSelector
+	}
+	if !hasFFmpeg() {
+		// Nothing can merge separate streams, so take files that have both
+		formatCode = singleFileSelector(formatCode, singleFileFormatCode(quality))
+	}
 
-	// Build command arguments
+	// Build arguments for yt-dlp
 	ytdlpArgs := []string{
-		"-x",
-		"--audio-format", "mp3",
-		"--audio-quality", "0",
-		"-o", audioOutput,
+		"-f", formatCode,
+		"-o", output,
 		"--newline",
 		"--progress-template", "%(progress.downloaded_bytes)s/%(progress.total_bytes)s",
 		"--no-playlist",
 	}
 
-	// Add platform-specific options
+	// Ensure proper container format unless the original file was asked for
+	if quality != "original" && hasFFmpeg() {
+		ytdlpArgs = append(ytdlpArgs, "--remux-video", "mp4")
+	}
+
+	// Add cookies for platforms that need authentication
 	switch info.Platform {
 	case "Instagram", "Facebook":
 		ytdlpArgs = append(ytdlpArgs, "--no-check-certificate")
 	}
 
-	// Add URL as final argument
-	ytdlpArgs = append(ytdlpArgs, info.URL)
-
-	// Create command
-	cmd := exec.Command("yt-dlp", ytdlpArgs...)
+	if info.Resume {
+		ytdlpArgs = append(ytdlpArgs, "--continue")
+	}
 
-	// Set up progress tracking
-	progressPipe, _ := cmd.StderrPipe()
+	ytdlpArgs = append(ytdlpArgs, liveArgs(info)...)
+	ytdlpArgs = append(ytdlpArgs, excerptArgs(info)...)
+	ytdlpArgs = append(ytdlpArgs, sponsorBlockArgs(info)...)
+	ytdlpArgs = append(ytdlpArgs, infoJSONArgs(info, strings.TrimSuffix(output, ".%(ext)s"))...)
+	return ytdlpArgs
+}
 
-	// Start the command
-	err := cmd.Start()
-	if err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to start audio extraction process."))
-		log.Println("Command start error:", err)
+func handleAudioDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, quality string, statusMsgID int) {
+	if !requireFFmpeg(bot, chatID) {
 		return
 	}
 
-	// Read progress updates
-	go trackProgress(bot, chatID, statusMsgID, progressPipe, info.Title, "MP3")
+	// Create unique filename with instance ID and timestamp
+	timestamp := fileStamp(info)
+	audioOutput := filePrefix("audio", timestamp) + ".%(ext)s"
 
-	// Wait for command to complete
-	err = cmd.Wait()
+	// Build command arguments
+	ytdlpArgs := buildAudioArgs(info, audioOutput)
+	label := "MP3"
+	embedChapters := quality == "embedchapters"
+	if embedChapters {
+		ytdlpArgs = withEmbeddedChapters(ytdlpArgs)
+		label = "M4A with chapters"
+	}
+	asVoice := quality == "voice"
+	if asVoice {
+		ytdlpArgs = withVoiceFormat(ytdlpArgs)
+		label = "voice message"
+	}
+	original := quality == "original"
+	if original {
+		ytdlpArgs = withOriginalAudio(ytdlpArgs)
+		label = "original audio"
+	}
+	// A source picked by the user is kept as it is, too
+	if formatID, ok := audioSourceID(quality); ok {
+		ytdlpArgs = withAudioSource(ytdlpArgs, formatID)
+		label = "audio source " + formatID
+		original = true
+	}
+
+	// Run yt-dlp, trying alternate YouTube player clients when needed
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, label)
 	if err != nil {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to extract audio."))
-		log.Println("Audio extraction error:", err)
+		if isFFmpegMissing(output) {
+			markFFmpegMissing(bot, info)
+			requireFFmpeg(bot, chatID)
+			return
+		}
+		reportDownloadFailure(bot, chatID, info, "audio extraction", err, output)
 		return
 	}
 
 	// Find downloaded file
-	audioFiles, _ := filepath.Glob(fmt.Sprintf("audio_%d.*", timestamp))
-	if len(audioFiles) == 0 {
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ No audio file found after extraction completed."))
+	audioFile, ok := findOutputFile(filePrefix("audio", timestamp))
+	if !ok {
+		sendFailure(bot, chatID, "❌ No audio file found after extraction completed.")
 		return
 	}
-	audioFile := audioFiles[0]
 	defer os.Remove(audioFile)
+	metaFile := infoJSONFile(filePrefix("audio", timestamp))
+	defer os.Remove(metaFile)
+
+	if err := runPostDownloadHook(runner, audioFile); err != nil {
+		jobLog(info).Println(err)
+		sendFailure(bot, chatID, hookRejectedMessage)
+		return
+	}
+
+	// Optionally even out the volume; this re-encodes, so it's opt-in
+	normalized := quality == "loudnorm"
+	if normalized {
+		editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+			buildMessage(info.Title, func(title string) string {
+				return fmt.Sprintf("🎚 "+bold("Normalizing volume...")+"\n\n%s", title)
+			}),
+		)
+		editMsg.ParseMode = parseMode
+		bot.Send(editMsg)
+
+		normalizedFile, err := normalizeLoudness(runner, audioFile)
+		if err != nil {
+			jobLog(info).Println(err)
+			sendFailure(bot, chatID, "❌ Failed to normalize the audio volume.")
+			return
+		}
+		defer os.Remove(normalizedFile)
+		audioFile = normalizedFile
+	}
 
 	// Get file info
 	fileInfo, err := os.Stat(audioFile)
 	if err != nil {
-		log.Println("Failed to get file info:", err)
+		jobLog(info).Println("Failed to get file info:", err)
 	}
 
 	// Convert bytes to MB
@@ -476,56 +1405,280 @@ func handleAudioDownload(bot *tgbotapi.BotAPI, chatID int64, info Download, stat
 	editMsg := tgbotapi.NewEditMessageText(
 		chatID,
 		statusMsgID,
-		fmt.Sprintf("✅ *Audio Extraction Complete!*\n\n%s\n\nUploading to Telegram...",
-			truncateString(info.Title, 150)),
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("✅ "+bold("Audio Extraction Complete!")+"\n\n%s\n\nUploading to Telegram...", title)
+		}),
 	)
-	editMsg.ParseMode = "Markdown"
+	editMsg.ParseMode = parseMode
 	bot.Send(editMsg)
 
 	// Check if file is too large
 	if fileInfo.Size() > MaxFileSize {
+		if sendOversized(bot, chatID, info, audioFile, fileSizeMB) {
+			return
+		}
 		bot.Send(tgbotapi.NewMessage(chatID,
 			fmt.Sprintf("⚠️ Audio file (%.1f MB) exceeds Telegram's limit.", fileSizeMB)))
 		return
 	}
+	if asVoice && fileInfo.Size() > MaxVoiceSize {
+		sendFailure(bot, chatID, fmt.Sprintf("⚠️ Audio (%.1f MB) is too large for a voice message. Try the MP3 option instead.", fileSizeMB))
+		return
+	}
+
+	// Check that the chapter markers made it into the file
+	chapterNote := ""
+	if embedChapters {
+		count, err := probeChapterCount(runner, audioFile)
+		switch {
+		case err != nil:
+			jobLog(info).Println("Failed to count chapters:", err)
+		case count == 0:
+			chapterNote = "\n▫️ Chapters could not be embedded"
+		default:
+			chapterNote = fmt.Sprintf("\n▫️ Chapters: %d embedded", count)
+		}
+	}
 
-	// Format caption
-	caption := fmt.Sprintf("🎵 *%s* - %s\n▫️ Format: MP3\n▫️ Size: %.1f MB",
-		info.Platform,
-		truncateString(info.Title, 100),
-		fileSizeMB)
+	// Format caption. buildCaption may render it more than once while fitting
+	// the title, so ffprobe runs here rather than in the closure.
+	audioFormat := strings.ToUpper(strings.TrimPrefix(filepath.Ext(audioFile), "."))
+	notes := musicNote(info.Meta) + audioStreamNote(runner, info, audioFile) + uploadDateNote(info.Meta) + chapterNote
+	caption := buildCaption(info.Title, func(title string) string {
+		caption := fmt.Sprintf("🎵 "+bold("%s")+" - %s\n▫️ Format: %s\n▫️ Size: %.1f MB%s",
+			info.Platform, title, audioFormat, fileSizeMB, notes)
+		if normalized {
+			caption += "\n▫️ Volume: normalized (EBU R128)"
+		}
+		if original {
+			caption += "\n▫️ Original codec, not re-encoded"
+		}
+		return caption
+	})
 
 	// Send audio
-	audio := tgbotapi.NewAudio(chatID, tgbotapi.FilePath(audioFile))
-	audio.Caption = caption
-	audio.ParseMode = "Markdown"
-	audio.Title = info.Title
-	if _, err := bot.Send(audio); err != nil {
-		log.Println("Failed to send audio:", err)
-		bot.Send(tgbotapi.NewMessage(chatID, "❌ Failed to send audio. File might be too large for Telegram."))
+	upload, file, err := openUpload(bot, chatID, statusMsgID, audioFile, info)
+	if err != nil {
+		jobLog(info).Println("Failed to open audio file:", err)
+		sendFailure(bot, chatID, "❌ Failed to send audio.")
+		return
+	}
+	defer file.Close()
+
+	var send tgbotapi.Chattable
+	if asVoice {
+		send = newVoiceUpload(chatID, upload, info.Meta, caption)
+	} else if !playsAsAudio(audioFile) {
+		// Opus and other original codecs go as a file under their own extension
+		document := tgbotapi.NewDocument(chatID, upload)
+		document.Caption = caption
+		document.ParseMode = parseMode
+		send = document
+	} else {
+		audio := tgbotapi.NewAudio(chatID, upload)
+		audio.Caption = caption
+		audio.ParseMode = parseMode
+		audio.Title = info.Title
+		if info.Meta != nil && info.Meta.Track != "" {
+			audio.Title = info.Meta.Track
+		}
+		audio.Performer = audioPerformer(info.Meta)
+		// The file itself knows its length best; the metadata is a fallback
+		if media, err := probeMedia(runner, audioFile); err == nil && media.Duration > 0 {
+			audio.Duration = media.Duration
+		} else if info.Meta != nil {
+			audio.Duration = int(info.Meta.Duration)
+		}
+		// Cover art for the player, from the video's thumbnail
+		if info.Thumbnail != "" {
+			coverFile := filePrefix("cover", timestamp) + ".jpg"
+			if err := prepareThumbnail(runner, info.Thumbnail, coverFile, 0); err != nil {
+				jobLog(info).Println("Failed to prepare cover art:", err)
+			} else {
+				defer os.Remove(coverFile)
+				audio.Thumb = tgbotapi.FilePath(coverFile)
+			}
+		}
+		send = audio
+	}
+	if _, err := sendWithRetry(bot, send, file); isFileTooBig(err) {
+		file.Close()
+		handleRejectedAsTooBig(bot, runner, chatID, info, "audio", quality, audioFile, fileSizeMB, statusMsgID)
+	} else if err != nil {
+		jobLog(info).Println("Failed to send audio:", err)
+		sendFailure(bot, chatID, "❌ Failed to send audio.")
+	} else {
+		downloadSucceeded(chatID)
+		sendExtras(bot, chatID, info, metaFile)
 	}
 }
 
-func trackProgress(bot *tgbotapi.BotAPI, chatID int64, statusMsgID int, progressReader io.Reader, title, quality string) {
-	scanner := bufio.NewScanner(progressReader)
-	lastUpdateTime := time.Now()
+// handleVideoAndAudioDownload sends the video and then a separate MP3 of it.
+// The two run one after the other so a single request never holds two slots,
+// and each file gets its own size check.
+func handleVideoAndAudioDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, quality string, statusMsgID int) {
+	handleVideoDownload(bot, runner, chatID, info, quality, statusMsgID)
+
+	// The video's status message is finished with, so the audio gets a fresh one
+	statusMsg, err := bot.Send(tgbotapi.NewMessage(chatID, "⏳ Extracting the audio..."))
+	if err != nil {
+		jobLog(info).Println("Failed to send status message:", err)
+		return
+	}
+	info.IsAudio = true
+	info.WithInfoJSON = false // already sent with the video
+	info.WithDescription = false
+	handleAudioDownload(bot, runner, chatID, info, "mp3", statusMsg.MessageID)
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+// resolveFormatCode picks the yt-dlp format selector for a platform and quality.
+// Operators can replace any of them through formatOverrides.
+func resolveFormatCode(platform, quality string) string {
+	if code, ok := formatOverride(platform, quality); ok {
+		return code
+	}
 
+	// The best single file as published, whatever its container
+	if quality == "original" {
+		return "b"
+	}
+	// The tiniest playable file, for poor connections
+	if quality == "smallest" {
+		return "worst[ext=mp4]/worst/wv*+wa"
+	}
+
+	switch {
+	case platform == "YouTube":
+		switch quality {
+		case "360p":
+			return "18/bestvideo[height<=360]+bestaudio/best[height<=360]"
+		case "480p":
+			return "135+bestaudio/bestvideo[height<=480]+bestaudio/best[height<=480]"
+		case "720p":
+			return "22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]"
+		}
+		if height, ok := parseHeight(quality); ok {
+			return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]", height, height)
+		}
+		return "best"
+	case platform == "TikTok" && quality == "nowm":
+		// TikTok's download_addr format is the watermarked one; prefer play_addr and
+		// the other clean formats, falling back to the watermark only if that's all there is
+		return "best[format_id!^=download][format_note!*=watermark][ext=mp4]/" +
+			"best[format_id!^=download][format_note!*=watermark]/best[ext=mp4]/best"
+	case platform == "Instagram" || platform == "Facebook" || platform == "TikTok":
+		if height, ok := parseHeight(quality); ok {
+			return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]", height, height)
+		}
+		switch quality {
+		case "medium":
+			return "worst[ext=mp4]/worst"
+		default:
+			return "best[ext=mp4]/best"
+		}
+	default:
+		if height, ok := parseHeight(quality); ok {
+			return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]", height, height)
+		}
+		return "best"
+	}
+}
+
+// parseHeight extracts the height from a quality label such as "720p"
+func parseHeight(quality string) (int, bool) {
+	if !strings.HasSuffix(quality, "p") {
+		return 0, false
+	}
+	height, err := strconv.Atoi(strings.TrimSuffix(quality, "p"))
+	if err != nil || height <= 0 {
+		return 0, false
+	}
+	return height, true
+}
+
+// buildAudioArgs returns the yt-dlp arguments (without the URL) for an MP3 extraction
+func buildAudioArgs(info Download, output string) []string {
+	ytdlpArgs := []string{
+		"-x",
+		"--audio-format", "mp3",
+		"--audio-quality", "0",
+		"-o", output,
+		"--newline",
+		"--progress-template", "%(progress.downloaded_bytes)s/%(progress.total_bytes)s",
+		"--no-playlist",
+		// Start from the highest-bitrate audio stream, whatever gets done to it
+		"-S", "abr",
+	}
+
+	// Pick the requested audio track when the video has several
+	if info.AudioLanguage != "" {
+		ytdlpArgs = append(ytdlpArgs, "-f", fmt.Sprintf("bestaudio[language=%s]/bestaudio/best", info.AudioLanguage))
+	}
+
+	// Add platform-specific options
+	switch info.Platform {
+	case "Instagram", "Facebook":
+		ytdlpArgs = append(ytdlpArgs, "--no-check-certificate")
+	}
+	if info.Resume {
+		ytdlpArgs = append(ytdlpArgs, "--continue")
+	}
+
+	ytdlpArgs = append(ytdlpArgs, musicArgs(info)...)
+	ytdlpArgs = append(ytdlpArgs, infoJSONArgs(info, strings.TrimSuffix(output, ".%(ext)s"))...)
+	return ytdlpArgs
+}
+
+// runDownload runs yt-dlp with the given arguments, relaying progress to the status
+// message, and returns everything yt-dlp printed for error inspection
+func runDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, statusMsgID int, info Download, args []string, quality string) (string, error) {
+	var output strings.Builder
+	onProgress := trackProgress(bot, chatID, statusMsgID, info.Title, quality)
+	logger := jobLog(info)
+
+	err := runner.Stream(context.Background(), func(line string) {
+		output.WriteString(line)
+		output.WriteByte('\n')
+		// Errors go to the log as they happen, tagged with the job
+		if strings.HasPrefix(line, "ERROR:") {
+			logger.Println("yt-dlp:", line)
+		}
+		onProgress(line)
+	}, "yt-dlp", args...)
+	if err != nil {
+		logger.Printf("yt-dlp exited: %v", err)
+	}
+
+	return output.String(), err
+}
+
+// trackProgress returns a line handler that edits the status message with the
+// download percentage, at most once every UpdateIntervalSec
+func trackProgress(bot *tgbotapi.BotAPI, chatID int64, statusMsgID int, title, quality string) func(line string) {
+	lastUpdateTime := time.Now()
+	lastText := ""
+
+	return func(line string) {
 		// Parse progress info from line
 		progress := parseProgress(line)
 		if progress > 0 && time.Since(lastUpdateTime).Seconds() >= UpdateIntervalSec {
+			text := buildMessage(title, func(title string) string {
+				return fmt.Sprintf("⏳ "+bold("Processing %s download")+"\n\n%s\n\n%d%% complete...",
+					escapeText(quality), title, progress)
+			})
+			// The same percentage again would only earn a "not modified" error
+			if text == lastText {
+				return
+			}
+
 			// Update message with progress
-			editMsg := tgbotapi.NewEditMessageText(
-				chatID,
-				statusMsgID,
-				fmt.Sprintf("⏳ *Processing %s download*\n\n%s\n\n%d%% complete...",
-					quality, truncateString(title, 150), progress),
-			)
-			editMsg.ParseMode = "Markdown"
-			bot.Send(editMsg)
+			editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID, text)
+			editMsg.ParseMode = parseMode
+			if _, err := bot.Send(editMsg); err != nil && !isNotModified(err) {
+				log.Println("Failed to update download progress:", err)
+			}
 
+			lastText = text
 			lastUpdateTime = time.Now()
 		}
 	}
@@ -549,8 +1702,12 @@ func parseProgress(line string) int {
 }
 
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	if maxLen <= 3 {
+		return string(runes[:maxLen])
+	}
+	return string(runes[:maxLen-3]) + "..."
 }
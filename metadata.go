@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// VideoFormat is one entry of the "formats" list in yt-dlp's JSON output
+type VideoFormat struct {
+	FormatID       string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	FPS            float64 `json:"fps"`
+	VCodec         string  `json:"vcodec"`
+	ACodec         string  `json:"acodec"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+	TBR            float64 `json:"tbr"` // total bitrate in kbit/s
+	ABR            float64 `json:"abr"` // audio bitrate in kbit/s
+	Language       string  `json:"language"`
+	// LanguagePreference ranks audio tracks; yt-dlp gives the original the highest
+	LanguagePreference int    `json:"language_preference"`
+	FormatNote         string `json:"format_note"`
+	// DynamicRange is "SDR", "HDR10", "HLG", "DV"... or empty when unknown
+	DynamicRange string `json:"dynamic_range"`
+}
+
+// HasVideo reports whether the format carries a video stream
+func (f VideoFormat) HasVideo() bool {
+	return f.VCodec != "" && f.VCodec != "none"
+}
+
+// HasAudio reports whether the format carries an audio stream
+func (f VideoFormat) HasAudio() bool {
+	return f.ACodec != "" && f.ACodec != "none"
+}
+
+// Size returns the exact or approximate size in bytes, or 0 when unknown
+func (f VideoFormat) Size() int64 {
+	if f.Filesize > 0 {
+		return f.Filesize
+	}
+	return f.FilesizeApprox
+}
+
+// VideoMetadata is the subset of `yt-dlp -J` output the bot uses
+type VideoMetadata struct {
+	Title       string        `json:"title"`
+	Thumbnail   string        `json:"thumbnail"`
+	Duration    float64       `json:"duration"`
+	Formats     []VideoFormat `json:"formats"`
+	Chapters    []Chapter     `json:"chapters"`
+	Language    string        `json:"language"`
+	Description string        `json:"description"`
+	Uploader    string        `json:"uploader"`
+	// Live streams report is_live, and the start of their DVR window as release_timestamp
+	IsLive           bool  `json:"is_live"`
+	ReleaseTimestamp int64 `json:"release_timestamp"`
+	// UploadDate is the publish date as YYYYMMDD, empty when unknown
+	UploadDate string `json:"upload_date"`
+	// Music metadata, filled in for YouTube Music tracks
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Track  string `json:"track"`
+	// Subtitle tracks keyed by language; only the keys are kept
+	Subtitles         map[string]captionTracks `json:"subtitles"`
+	AutomaticCaptions map[string]captionTracks `json:"automatic_captions"`
+}
+
+// captionTracks stands for the list of caption files yt-dlp gives per language.
+// Only the language matters to the bot, and the lists run to hundreds of KB on
+// YouTube, so they are skipped rather than kept in the prompt cache.
+type captionTracks struct{}
+
+func (*captionTracks) UnmarshalJSON([]byte) error { return nil }
+
+// DefaultMetadataTimeout bounds how long fetching a video's metadata may take
+const DefaultMetadataTimeout = 20 * time.Second
+
+// metadataTimeout is read from METADATA_TIMEOUT (in seconds) at startup
+var metadataTimeout = loadMetadataTimeout()
+
+func loadMetadataTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("METADATA_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		return DefaultMetadataTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// DefaultMaxConcurrentMetadata bounds how many metadata fetches run at once
+const DefaultMaxConcurrentMetadata = 4
+
+// metadataSlots limits concurrent yt-dlp -J processes, so a flood of links
+// queues up instead of launching one process per link. It is separate from the
+// download queue, which would otherwise block the cheap fetches behind long
+// downloads. Sized from MAX_CONCURRENT_METADATA at startup.
+var metadataSlots = make(chan struct{}, loadMaxConcurrentMetadata())
+
+func loadMaxConcurrentMetadata() int {
+	slots, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_METADATA"))
+	if err != nil || slots <= 0 {
+		return DefaultMaxConcurrentMetadata
+	}
+	return slots
+}
+
+// fetchMetadata asks yt-dlp for the full JSON description of a single video
+func fetchMetadata(ctx context.Context, runner Runner, url string) (*VideoMetadata, error) {
+	args := []string{"-J", "--no-playlist"}
+	if isYouTubeMusicAlbum(url) {
+		// Only the album itself is needed, not every track's formats
+		args = []string{"-J", "--flat-playlist"}
+	}
+	output, err := runner.Run(ctx, "yt-dlp", withExtraArgs(args, url)...)
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp -J failed: %w", err)
+	}
+
+	var meta VideoMetadata
+	if err := json.Unmarshal(output, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp JSON: %w", err)
+	}
+	return &meta, nil
+}
+
+// availableHeights lists the distinct video heights a video is offered in, lowest first
+func availableHeights(meta *VideoMetadata) []int {
+	if meta == nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var heights []int
+	for _, f := range meta.Formats {
+		if !f.HasVideo() || f.Height <= 0 || seen[f.Height] {
+			continue
+		}
+		seen[f.Height] = true
+		heights = append(heights, f.Height)
+	}
+	sort.Ints(heights)
+	return heights
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isYouTubeMusicURL reports whether a link is from music.youtube.com, which
+// gets an audio-first keyboard
+func isYouTubeMusicURL(rawURL string) bool {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(parsed.Hostname(), "music.youtube.com")
+}
+
+// isYouTubeMusicAlbum reports whether a YouTube Music link is an album (or
+// other playlist) rather than a single track
+func isYouTubeMusicAlbum(rawURL string) bool {
+	if !isYouTubeMusicURL(rawURL) {
+		return false
+	}
+	parsed, _ := neturl.Parse(rawURL)
+	switch {
+	case parsed.Path == "/playlist":
+		return parsed.Query().Get("list") != ""
+	case strings.HasPrefix(parsed.Path, "/browse/MPREb"):
+		return true
+	}
+	return false
+}
+
+// musicArgs tags extracted audio with the track's artist, album and title and
+// embeds the cover art, for links from YouTube Music
+func musicArgs(info Download) []string {
+	if !isYouTubeMusicURL(info.URL) {
+		return nil
+	}
+	return []string{"--embed-metadata", "--embed-thumbnail"}
+}
+
+// audioPerformer is who Telegram's player shows as the performer: the artist
+// for music tracks, otherwise whoever uploaded the video
+func audioPerformer(meta *VideoMetadata) string {
+	if meta == nil {
+		return ""
+	}
+	if meta.Artist != "" {
+		return meta.Artist
+	}
+	return meta.Uploader
+}
+
+// musicNote describes a track for the audio caption
+func musicNote(meta *VideoMetadata) string {
+	if meta == nil {
+		return ""
+	}
+	note := ""
+	if meta.Artist != "" {
+		note += "\n▫️ Artist: " + escapeText(meta.Artist)
+	}
+	if meta.Album != "" {
+		note += "\n▫️ Album: " + escapeText(meta.Album)
+	}
+	return note
+}
+
+// createMusicKeyboard puts audio first for YouTube Music links, with the full
+// video keyboard one tap away. Albums are offered as a whole.
+func createMusicKeyboard(info Download) tgbotapi.InlineKeyboardMarkup {
+	if isYouTubeMusicAlbum(info.URL) {
+		return tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("💿 Full album as ZIP (up to %d tracks)", MaxPlaylistItems), "playlist:audiozip"),
+			),
+		)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎵 MP3 (best quality, tagged)", "audio:mp3"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📹 Video options", "music:video"),
+		),
+	)
+}
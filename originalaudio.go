@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// withOriginalAudio drops the MP3 conversion from audio arguments, so yt-dlp
+// only pulls the best audio stream out of its container (m4a, opus, ...)
+// without re-encoding it
+func withOriginalAudio(args []string) []string {
+	result := make([]string, 0, len(args)+2)
+	hasFormat := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--audio-format", "--audio-quality":
+			i++
+			continue
+		case "-f":
+			hasFormat = true
+		}
+		result = append(result, args[i])
+	}
+	if !hasFormat {
+		result = append(result, "-f", "bestaudio/best")
+	}
+	return result
+}
+
+// playsAsAudio reports whether Telegram's audio player takes the file; it
+// only handles MP3 and M4A, anything else has to go as a document
+func playsAsAudio(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3", ".m4a":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// supportedPlatforms lists every platform the bot knows how to handle, in the
+// order they are shown to users
+var supportedPlatforms = []string{"YouTube", "Instagram", "Facebook", "TikTok", "Loom"}
+
+// enabledPlatforms is the subset of supportedPlatforms allowed by ENABLED_PLATFORMS
+var enabledPlatforms = loadEnabledPlatforms()
+
+// DirectPlatformKey turns on direct media links in ENABLED_PLATFORMS. They
+// aren't a site, so they stay out of supportedPlatforms and the lists users see.
+const DirectPlatformKey = "direct"
+
+// directLinksEnabled reports whether ENABLED_PLATFORMS allows direct media links
+var directLinksEnabled = loadDirectLinksEnabled()
+
+func loadDirectLinksEnabled() bool {
+	value := os.Getenv("ENABLED_PLATFORMS")
+	if strings.TrimSpace(value) == "" {
+		return true
+	}
+	for _, name := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(name), DirectPlatformKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadEnabledPlatforms reads the comma-separated ENABLED_PLATFORMS variable.
+// When it is unset every supported platform is enabled.
+func loadEnabledPlatforms() []string {
+	value := os.Getenv("ENABLED_PLATFORMS")
+	if strings.TrimSpace(value) == "" {
+		return supportedPlatforms
+	}
+
+	var enabled []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.EqualFold(name, DirectPlatformKey) {
+			continue
+		}
+		found := false
+		for _, platform := range supportedPlatforms {
+			if strings.EqualFold(name, platform) {
+				enabled = append(enabled, platform)
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Printf("Ignoring unknown platform %q in ENABLED_PLATFORMS", name)
+		}
+	}
+	return enabled
+}
+
+func isPlatformEnabled(platform string) bool {
+	if platform == DirectPlatform {
+		return directLinksEnabled
+	}
+	for _, enabled := range enabledPlatforms {
+		if enabled == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledPlatformList renders the enabled platforms as "A, B, or C"
+func enabledPlatformList() string {
+	switch len(enabledPlatforms) {
+	case 0:
+		return "no platforms"
+	case 1:
+		return enabledPlatforms[0]
+	}
+	last := len(enabledPlatforms) - 1
+	return strings.Join(enabledPlatforms[:last], ", ") + ", or " + enabledPlatforms[last]
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxPlaylistItems caps how many videos a whole-playlist download fetches
+const MaxPlaylistItems = 25
+
+// DefaultPlaylistConcurrency is how many playlist videos download at once
+const DefaultPlaylistConcurrency = 3
+
+// playlistConcurrency is read from PLAYLIST_CONCURRENCY at startup. Every item
+// beyond the first needs a free slot in the download queue, so a playlist never
+// pushes the bot past MAX_CONCURRENT_DOWNLOADS.
+var playlistConcurrency = loadPlaylistConcurrency()
+
+func loadPlaylistConcurrency() int {
+	workers, err := strconv.Atoi(os.Getenv("PLAYLIST_CONCURRENCY"))
+	if err != nil || workers <= 0 {
+		return DefaultPlaylistConcurrency
+	}
+	return workers
+}
+
+// playlistID returns the list= parameter of a YouTube video link, if it has one.
+// Such links open a single video inside a playlist.
+func playlistID(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get("list")
+}
+
+// createPlaylistRow offers the rest of the playlist for links that are part of
+// one, as videos or as a ZIP of the audio
+func createPlaylistRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if info.Platform != "YouTube" || playlistID(info.URL) == "" {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📃 Whole playlist (up to %d)", MaxPlaylistItems),
+			"playlist:video"),
+		tgbotapi.NewInlineKeyboardButtonData("🗜 Playlist audio (ZIP)", "playlist:audiozip"),
+	)
+}
+
+// handlePlaylistDownload downloads the playlist the link belongs to and sends
+// the videos one by one in playlist order
+func handlePlaylistDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, statusMsgID int) {
+	timestamp := time.Now().UnixNano()
+	prefix := filePrefix("playlist", timestamp)
+
+	// Without ffmpeg nothing can merge separate streams or remux them
+	formatCode := resolveFormatCode(info.Platform, "720p")
+	if !hasFFmpeg() {
+		formatCode = singleFileSelector(formatCode, singleFileFormatCode("720p"))
+	}
+	ytdlpArgs := []string{
+		"-f", formatCode,
+		"-o", prefix + "_%(playlist_index)03d.%(ext)s",
+		"--newline",
+		"--progress-template", "%(progress.downloaded_bytes)s/%(progress.total_bytes)s",
+		"--yes-playlist",
+	}
+	if hasFFmpeg() {
+		ytdlpArgs = append(ytdlpArgs, "--remux-video", "mp4")
+	}
+
+	var output string
+	var err error
+	if count, listErr := countPlaylistItems(runner, info); listErr == nil && count > 1 && playlistConcurrency > 1 {
+		output, err = downloadPlaylistItems(bot, runner, chatID, statusMsgID, info, ytdlpArgs, count)
+	} else {
+		// Without the item count, fetch the list in a single run
+		ytdlpArgs = append(ytdlpArgs,
+			"--playlist-end", strconv.Itoa(MaxPlaylistItems),
+			// One unavailable video shouldn't sink the whole list
+			"--ignore-errors",
+		)
+		output, err = downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, "playlist")
+	}
+
+	matches, globErr := filepath.Glob(prefix + "_*")
+	if globErr != nil {
+		jobLog(info).Println("Failed to list playlist files:", globErr)
+		sendFailure(bot, chatID, "❌ The playlist files couldn't be found after the download.")
+		return
+	}
+	defer func() {
+		for _, file := range matches {
+			os.Remove(file)
+		}
+	}()
+
+	// Items that failed under --ignore-errors can leave partial files behind
+	var files []string
+	for _, file := range matches {
+		if !isPartialFile(file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	// With --ignore-errors a failed item still fails the run, so only give up
+	// when nothing was downloaded at all
+	if len(files) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no files downloaded")
+		}
+		reportDownloadFailure(bot, chatID, info, "playlist download", err, output)
+		return
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+		fmt.Sprintf("✅ "+bold("Playlist downloaded!")+"\n\nUploading %d video(s) to Telegram...", len(files)))
+	editMsg.ParseMode = parseMode
+	bot.Send(editMsg)
+
+	skipped := 0
+	for i, file := range files {
+		if err := runPostDownloadHook(runner, file); err != nil {
+			jobLog(info).Printf("Playlist item %d: %v", i+1, err)
+			skipped++
+			continue
+		}
+		if !sendPlaylistItem(bot, runner, chatID, file, i+1, len(files)) {
+			skipped++
+		}
+	}
+
+	if skipped > 0 {
+		sendFailure(bot, chatID, fmt.Sprintf("⚠️ %d of %d videos could not be sent.", skipped, len(files)))
+		return
+	}
+	downloadSucceeded(chatID)
+}
+
+// countPlaylistItems asks yt-dlp how many videos the playlist has, up to
+// MaxPlaylistItems, without downloading any of them
+func countPlaylistItems(runner Runner, info Download) (int, error) {
+	metadataSlots <- struct{}{}
+	defer func() { <-metadataSlots }()
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+
+	output, err := runner.Run(ctx, "yt-dlp", withExtraArgs([]string{
+		"--flat-playlist", "--yes-playlist",
+		"--playlist-end", strconv.Itoa(MaxPlaylistItems),
+		"--print", "id",
+	}, info.URL)...)
+	if err != nil {
+		return 0, err
+	}
+	return len(strings.Fields(string(output))), nil
+}
+
+// downloadPlaylistItems downloads items 1 to count with one yt-dlp run each,
+// several at a time: one on the slot the job already holds, plus one for every
+// other queue slot that happens to be free. It returns the output of the last
+// failed item, and an error only when every item failed.
+func downloadPlaylistItems(bot *tgbotapi.BotAPI, runner Runner, chatID int64, statusMsgID int, info Download, args []string, count int) (string, error) {
+	workers := 1
+	for workers < playlistConcurrency && workers < count && downloads.tryAcquire() {
+		workers++
+	}
+	defer func() {
+		for i := 1; i < workers; i++ {
+			downloads.release()
+		}
+	}()
+	jobLog(info).Printf("Downloading %d playlist items, %d at a time", count, workers)
+
+	progress := newBatchProgress(bot, chatID, statusMsgID, count)
+	items := make(chan int)
+	var mu sync.Mutex
+	var lastOutput string
+	var lastErr error
+	failed := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				itemArgs := append(append([]string{}, args...), "--playlist-items", strconv.Itoa(item))
+				var output strings.Builder
+				err := runner.Stream(context.Background(), func(line string) {
+					output.WriteString(line)
+					output.WriteByte('\n')
+					if percent := parseProgress(line); percent > 0 {
+						progress.update(item, percent)
+					}
+				}, "yt-dlp", withExtraArgs(itemArgs, info.URL)...)
+				progress.finish(item)
+
+				if err != nil {
+					jobLog(info).Printf("Playlist item %d failed: %v", item, err)
+					mu.Lock()
+					lastOutput, lastErr = output.String(), err
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for item := 1; item <= count; item++ {
+		items <- item
+	}
+	close(items)
+	wg.Wait()
+
+	recordDownloadResult(info.Platform, lastErr, lastOutput)
+	if failed == count {
+		return lastOutput, lastErr
+	}
+	return lastOutput, nil
+}
+
+// batchProgress combines the progress of concurrently downloading items into
+// one status message, edited at most once every UpdateIntervalSec
+type batchProgress struct {
+	mu          sync.Mutex
+	bot         *tgbotapi.BotAPI
+	chatID      int64
+	statusMsgID int
+	percents    []int
+	done        int
+	lastUpdate  time.Time
+	lastText    string
+}
+
+func newBatchProgress(bot *tgbotapi.BotAPI, chatID int64, statusMsgID int, count int) *batchProgress {
+	return &batchProgress{
+		bot:         bot,
+		chatID:      chatID,
+		statusMsgID: statusMsgID,
+		percents:    make([]int, count),
+		lastUpdate:  time.Now(),
+	}
+}
+
+// update records an item's (1-based) download percentage
+func (p *batchProgress) update(item, percent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.percents[item-1] = percent
+	p.report()
+}
+
+// finish marks an item as done, whether or not it worked
+func (p *batchProgress) finish(item int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.percents[item-1] = 100
+	p.done++
+	p.report()
+}
+
+// report edits the status message with the overall progress; p.mu must be held
+func (p *batchProgress) report() {
+	if time.Since(p.lastUpdate).Seconds() < UpdateIntervalSec {
+		return
+	}
+	total := 0
+	for _, percent := range p.percents {
+		total += percent
+	}
+	text := fmt.Sprintf("⏳ "+bold("Downloading playlist")+"\n\n%d of %d videos done\n\n%d%% complete...",
+		p.done, len(p.percents), total/len(p.percents))
+	if text == p.lastText {
+		return
+	}
+	editMsg := tgbotapi.NewEditMessageText(p.chatID, p.statusMsgID, text)
+	editMsg.ParseMode = parseMode
+	if _, err := p.bot.Send(editMsg); err != nil && !isNotModified(err) {
+		log.Println("Failed to update playlist progress:", err)
+	}
+	p.lastText = text
+	p.lastUpdate = time.Now()
+}
+
+// sendPlaylistItem sends one downloaded playlist video, reporting whether it worked
+func sendPlaylistItem(bot *tgbotapi.BotAPI, runner Runner, chatID int64, path string, index, total int) bool {
+	stat, err := os.Stat(path)
+	if err != nil {
+		log.Println("Failed to stat playlist item:", err)
+		return false
+	}
+	if stat.Size() > MaxFileSize {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"⚠️ Video %d of %d (%.1f MB) exceeds Telegram's limit and was skipped.", index, total, float64(stat.Size())/1048576)))
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Println("Failed to open playlist item:", err)
+		return false
+	}
+	defer file.Close()
+
+	caption := fmt.Sprintf("📃 Playlist video %d of %d\n▫️ Size: %.1f MB", index, total, float64(stat.Size())/1048576)
+	upload := tgbotapi.FileReader{Name: filepath.Base(path), Reader: file}
+
+	media := unprobedVideo(nil)
+	if hasFFprobe() {
+		media, err = probeMedia(runner, path)
+	}
+	if err != nil || !media.HasVideo {
+		document := tgbotapi.NewDocument(chatID, upload)
+		document.Caption = caption
+		_, err = sendWithRetry(bot, document, file)
+	} else {
+		err = retryTransient(func() error {
+			_, err := sendVideoFile(bot, chatID, upload, caption, media, "", sendAsDocument(path))
+			return err
+		}, file)
+	}
+	if err != nil {
+		log.Println("Failed to send playlist item:", err)
+		return false
+	}
+	return true
+}
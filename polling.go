@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Backoff bounds for reconnecting to Telegram after a failed poll
+const (
+	MinPollBackoff = 1 * time.Second
+	MaxPollBackoff = 2 * time.Minute
+)
+
+// pollUpdates long-polls Telegram for updates and delivers them on the returned
+// channel. Unlike bot.GetUpdatesChan, which retries every 3 seconds forever, it
+// backs off exponentially while Telegram is unreachable and logs when the
+// connection is lost and restored, so the bot rides out network outages.
+// Polling stops once ctx is cancelled.
+func pollUpdates(ctx context.Context, bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	updates := make(chan tgbotapi.Update, bot.Buffer)
+
+	go func() {
+		backoff := MinPollBackoff
+		failing := false
+
+		for ctx.Err() == nil {
+			batch, err := bot.GetUpdates(config)
+			if err != nil {
+				log.Printf("Failed to get updates, retrying in %s: %v", backoff, err)
+				failing = true
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+
+				backoff *= 2
+				if backoff > MaxPollBackoff {
+					backoff = MaxPollBackoff
+				}
+				continue
+			}
+
+			if failing {
+				log.Println("Connection to Telegram restored")
+				failing = false
+			}
+			backoff = MinPollBackoff
+
+			for _, update := range batch {
+				if update.UpdateID >= config.Offset {
+					config.Offset = update.UpdateID + 1
+					select {
+					case updates <- update:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return updates
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo describes the streams found in a downloaded file
+type MediaInfo struct {
+	HasVideo bool
+	HasAudio bool
+	Width    int
+	Height   int
+	Duration int // seconds
+	// Rotation is the clockwise rotation players must apply, in degrees (0, 90,
+	// 180 or 270). Width and Height are already swapped to match it.
+	Rotation int
+	// HDR is set when the video uses an HDR transfer curve
+	HDR bool
+}
+
+// ffprobeOutput mirrors the subset of `ffprobe -of json` we care about
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		// ColorTransfer tells HDR (smpte2084, arib-std-b67) from SDR
+		ColorTransfer string `json:"color_transfer"`
+		// Older files carry a rotate tag, newer ones a display matrix
+		Tags struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probeMedia inspects a file with ffprobe to find out what it actually contains
+func probeMedia(runner Runner, path string) (MediaInfo, error) {
+	var info MediaInfo
+
+	output, err := runner.Run(context.Background(), "ffprobe",
+		"-v", "error",
+		"-show_entries", "stream=codec_type,width,height,color_transfer:stream_tags=rotate:stream_side_data=rotation:format=duration",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		return info, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return info, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Keep the first video stream; cover art can show up as a second one
+			if !info.HasVideo {
+				info.HasVideo = true
+				info.Width = stream.Width
+				info.Height = stream.Height
+				info.HDR = isHDRTransfer(stream.ColorTransfer)
+
+				if rotate, err := strconv.Atoi(stream.Tags.Rotate); err == nil {
+					info.Rotation = normalizeRotation(rotate)
+				}
+				for _, sideData := range stream.SideDataList {
+					// The display matrix angle is counter-clockwise
+					if sideData.Rotation != 0 {
+						info.Rotation = normalizeRotation(-int(sideData.Rotation))
+					}
+				}
+				if info.Rotation == 90 || info.Rotation == 270 {
+					info.Width, info.Height = info.Height, info.Width
+				}
+			}
+		case "audio":
+			info.HasAudio = true
+		}
+	}
+
+	if duration, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = int(duration + 0.5)
+	}
+
+	return info, nil
+}
+
+// probeChapterCount returns how many chapter markers a file carries
+func probeChapterCount(runner Runner, path string) (int, error) {
+	output, err := runner.Run(context.Background(), "ffprobe",
+		"-v", "error",
+		"-show_chapters",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe struct {
+		Chapters []json.RawMessage `json:"chapters"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return len(probe.Chapters), nil
+}
+
+// normalizeRotation maps any angle to 0, 90, 180 or 270
+func normalizeRotation(degrees int) int {
+	return ((degrees % 360) + 360) % 360 / 90 * 90
+}
+
+// applyRotation re-encodes a rotated video so the rotation is baked into the
+// frames. Not every Telegram client honours rotation metadata, so without this
+// phone videos can show up sideways. ffmpeg rotates automatically when it
+// re-encodes; the metadata is then cleared so it isn't applied twice.
+func applyRotation(runner Runner, path string) (string, error) {
+	output := strings.TrimSuffix(path, filepath.Ext(path)) + "_upright.mp4"
+	_, err := runner.Run(context.Background(), "ffmpeg",
+		"-y",
+		"-v", "error",
+		"-i", path,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-c:a", "copy",
+		"-metadata:s:v:0", "rotate=0",
+		"-movflags", "+faststart",
+		output,
+	)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg rotation failed: %w", err)
+	}
+	return output, nil
+}
+
+// unprobedVideo stands in for probeMedia when ffprobe isn't available. Only
+// single-file formats are downloaded then, so the file is taken to be a video
+// with sound, as long as the metadata says.
+func unprobedVideo(meta *VideoMetadata) MediaInfo {
+	media := MediaInfo{HasVideo: true, HasAudio: true}
+	if meta != nil {
+		media.Duration = int(meta.Duration)
+	}
+	return media
+}
+
+// formatResolution renders a video's size as WxH, or "unknown" when it
+// couldn't be probed
+func formatResolution(media MediaInfo) string {
+	if media.Width == 0 || media.Height == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dx%d", media.Width, media.Height)
+}
+
+// formatDuration renders seconds as m:ss or h:mm:ss
+func formatDuration(seconds int) string {
+	h := seconds / 3600
+	m := (seconds % 3600) / 60
+	s := seconds % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
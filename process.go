@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDownloadTimeout bounds how long a single external command may run
+const DefaultDownloadTimeout = 30 * time.Minute
+
+// downloadTimeout is read from DOWNLOAD_TIMEOUT (in minutes) at startup
+var downloadTimeout = loadDownloadTimeout()
+
+func loadDownloadTimeout() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("DOWNLOAD_TIMEOUT"))
+	if err != nil || minutes <= 0 {
+		return DefaultDownloadTimeout
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// processRegistry tracks every child process the runner has started, so that
+// stragglers can be killed by the sweeper or on shutdown
+type processRegistry struct {
+	mu        sync.Mutex
+	processes map[int]trackedProcess
+}
+
+type trackedProcess struct {
+	name    string
+	started time.Time
+}
+
+var children = &processRegistry{processes: make(map[int]trackedProcess)}
+
+func (r *processRegistry) add(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.processes[cmd.Process.Pid] = trackedProcess{name: cmd.Path, started: time.Now()}
+}
+
+func (r *processRegistry) remove(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.processes, cmd.Process.Pid)
+}
+
+// sweep kills the process group of every child that has outlived maxAge
+func (r *processRegistry) sweep(maxAge time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for pid, process := range r.processes {
+		if time.Since(process.started) > maxAge {
+			log.Printf("Killing %s (pid %d), running for %s", process.name, pid, time.Since(process.started).Round(time.Second))
+			killProcessGroup(pid)
+			delete(r.processes, pid)
+		}
+	}
+}
+
+// killAll kills every tracked process group, used on shutdown
+func (r *processRegistry) killAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for pid := range r.processes {
+		killProcessGroup(pid)
+		delete(r.processes, pid)
+	}
+}
+
+// startProcessSweeper periodically kills child processes that should have been
+// stopped by their timeout but weren't, e.g. because their goroutine leaked
+func startProcessSweeper(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			// Allow some slack beyond the timeout before treating a process as stuck
+			children.sweep(downloadTimeout + time.Minute)
+		}
+	}()
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op where process groups aren't available;
+// cancellation falls back to killing the command itself
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process, as there is no group to target
+func killProcessGroup(pid int) {
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Kill()
+	}
+}
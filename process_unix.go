@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup starts the command in its own process group so that
+// cancelling it also kills anything it spawned (yt-dlp runs ffmpeg, for one)
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// killProcessGroup kills the whole process group led by pid
+func killProcessGroup(pid int) {
+	syscall.Kill(-pid, syscall.SIGKILL)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePurge lets an admin reset the bot's state without a restart: it drops
+// the cached download prompts and deletes leftover temp files. Downloads that
+// are running are left alone.
+func handlePurge(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if message.From == nil || !isAdmin(message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "🔒 This command is only available to admins."))
+		return
+	}
+
+	prompts := urlCache.purge()
+	files, freed := removeStaleTempFiles()
+	log.Printf("Admin %d purged %d cached prompt(s) and %d temp file(s) (%.1f MB)",
+		message.From.ID, prompts, files, float64(freed)/1048576)
+
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf(
+		"🧹 Purge complete\n▫️ Cached prompts cleared: %d\n▫️ Temp files removed: %d (%.1f MB)\n\n"+
+			"Buttons on the cleared prompts no longer work; send the link again to get new ones.",
+		prompts, files, float64(freed)/1048576)))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultMaxConcurrentDownloads bounds how many downloads run at once
+const DefaultMaxConcurrentDownloads = 3
+
+// ShutdownGracePeriod is how long a shutdown waits for running downloads
+const ShutdownGracePeriod = 2 * time.Minute
+
+// inFlight counts downloads that have been started, queued or running, and the
+// handlers that may start one, so a shutdown can wait for them
+var inFlight sync.WaitGroup
+
+// goInFlight runs fn in its own goroutine, counted by inFlight. The update loop
+// calls it before handing work off, so every Add happens before the loop stops
+// and the shutdown's Wait never races a late one.
+func goInFlight(fn func()) {
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Done()
+		fn()
+	}()
+}
+
+// waitForDownloads waits for in-flight downloads, reporting whether they all
+// finished within the timeout
+func waitForDownloads(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// downloadQueue hands out a fixed number of download slots in the order they
+// were asked for, telling each waiting job where it stands as the line moves
+type downloadQueue struct {
+	mu      sync.Mutex
+	slots   int
+	active  int
+	waiting []*queuedJob
+}
+
+type queuedJob struct {
+	ready      chan struct{}
+	onPosition func(position int)
+	// position is the job's place in line, 0 once it has a slot. It is guarded
+	// by the queue's mutex.
+	position int
+	// notifyMu keeps the job's position updates from overtaking each other
+	notifyMu sync.Mutex
+}
+
+// downloads is sized from MAX_CONCURRENT_DOWNLOADS at startup
+var downloads = &downloadQueue{slots: loadMaxConcurrentDownloads()}
+
+func loadMaxConcurrentDownloads() int {
+	slots, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_DOWNLOADS"))
+	if err != nil || slots <= 0 {
+		return DefaultMaxConcurrentDownloads
+	}
+	return slots
+}
+
+// acquire blocks until a slot is free. While waiting, onPosition is called with
+// the job's 1-based place in line, first when it joins and again whenever it
+// moves up. It reports whether the job had to wait at all.
+func (q *downloadQueue) acquire(onPosition func(position int)) bool {
+	q.mu.Lock()
+	if q.active < q.slots && len(q.waiting) == 0 {
+		q.active++
+		q.mu.Unlock()
+		return false
+	}
+
+	job := &queuedJob{ready: make(chan struct{}), onPosition: onPosition}
+	q.waiting = append(q.waiting, job)
+	job.position = len(q.waiting)
+	q.mu.Unlock()
+
+	q.notify(job)
+	<-job.ready
+	return true
+}
+
+// notify shows a waiting job its current place in line. The position is read
+// under the lock when the update goes out rather than when it was queued, so a
+// slow update can't show a place the job has already moved past.
+func (q *downloadQueue) notify(job *queuedJob) {
+	job.notifyMu.Lock()
+	defer job.notifyMu.Unlock()
+
+	q.mu.Lock()
+	position := job.position
+	q.mu.Unlock()
+
+	if position > 0 {
+		job.onPosition(position)
+	}
+}
+
+// tryAcquire takes a slot only if one is free right now and nobody is waiting
+// for it. Jobs that already hold a slot use it to widen their own work, which
+// must never block, or two such jobs could end up waiting on each other.
+func (q *downloadQueue) tryAcquire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active < q.slots && len(q.waiting) == 0 {
+		q.active++
+		return true
+	}
+	return false
+}
+
+// release frees a slot, handing it straight to the next job in line
+func (q *downloadQueue) release() {
+	q.mu.Lock()
+	if len(q.waiting) == 0 {
+		q.active--
+		q.mu.Unlock()
+		return
+	}
+
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	next.position = 0
+	// Everyone still waiting moved up one place
+	for i, job := range q.waiting {
+		job.position = i + 1
+	}
+	remaining := append([]*queuedJob(nil), q.waiting...)
+	q.mu.Unlock()
+
+	close(next.ready)
+	for _, job := range remaining {
+		q.notify(job)
+	}
+}
+
+// DurationSamples is how many recent downloads the wait estimate averages over
+const DurationSamples = 20
+
+// durationTracker keeps a rolling average of how long downloads take
+type durationTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+// downloadDurations feeds the queue's wait estimates
+var downloadDurations = &durationTracker{}
+
+// record adds a finished download's duration, dropping the oldest sample once full
+func (t *durationTracker) record(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, d)
+	if len(t.samples) > DurationSamples {
+		t.samples = t.samples[len(t.samples)-DurationSamples:]
+	}
+}
+
+// average returns the mean of the recent samples, or false before the first one
+func (t *durationTracker) average() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, d := range t.samples {
+		total += d
+	}
+	return total / time.Duration(len(t.samples)), true
+}
+
+// estimatedWait guesses how long the job at position has to wait. The slots
+// work in parallel, so every slots jobs ahead cost one average download.
+func estimatedWait(position int) (time.Duration, bool) {
+	average, ok := downloadDurations.average()
+	if !ok {
+		return 0, false
+	}
+	rounds := (position + downloads.slots - 1) / downloads.slots
+	return time.Duration(rounds) * average, true
+}
+
+// formatWait renders a wait estimate in whole minutes
+func formatWait(d time.Duration) string {
+	if d < time.Minute {
+		return "under a minute"
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "about 1 minute"
+	}
+	return fmt.Sprintf("about %d minutes", minutes)
+}
+
+// queuePositionUpdater edits a status message with the job's place in the queue
+// and, once some downloads have finished, how long the wait will likely be
+func queuePositionUpdater(bot *tgbotapi.BotAPI, chatID int64, statusMsgID int, title string) func(position int) {
+	return func(position int) {
+		estimate := ""
+		if wait, ok := estimatedWait(position); ok {
+			estimate = "\n⏱ Estimated wait: " + formatWait(wait)
+		}
+		editMsg := tgbotapi.NewEditMessageText(chatID, statusMsgID,
+			buildMessage(title, func(title string) string {
+				return fmt.Sprintf("⏳ "+bold("You're #%d in the queue")+"\n\n%s\n\nYour download will start automatically.%s", position, title, estimate)
+			}),
+		)
+		editMsg.ParseMode = parseMode
+		bot.Send(editMsg)
+	}
+}
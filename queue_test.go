@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueuePositionsOnlyMoveUp(t *testing.T) {
+	q := &downloadQueue{slots: 2}
+	q.acquire(func(int) { t.Error("the first job shouldn't wait") })
+	q.acquire(func(int) { t.Error("the second job shouldn't wait") })
+
+	const waiters = 20
+	var mu sync.Mutex
+	seen := make([][]int, waiters)
+
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.acquire(func(position int) {
+				mu.Lock()
+				seen[i] = append(seen[i], position)
+				mu.Unlock()
+				// A slow status edit, so updates get a chance to overtake each other
+				time.Sleep(time.Millisecond)
+			})
+			q.release()
+		}()
+	}
+
+	// Start the line moving once everyone has joined it
+	for {
+		q.mu.Lock()
+		queued := len(q.waiting)
+		q.mu.Unlock()
+		if queued == waiters {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go q.release()
+	go q.release()
+	wg.Wait()
+
+	for i, positions := range seen {
+		if len(positions) == 0 {
+			t.Errorf("job %d was never told its position", i)
+			continue
+		}
+		for j := 1; j < len(positions); j++ {
+			if positions[j] > positions[j-1] {
+				t.Errorf("job %d moved back in line: %v", i, positions)
+				break
+			}
+		}
+	}
+	if q.active != 0 || len(q.waiting) != 0 {
+		t.Errorf("queue not empty afterwards: %d active, %d waiting", q.active, len(q.waiting))
+	}
+}
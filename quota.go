@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDailyQuota is how many downloads a user may start per day when
+// DAILY_QUOTA is unset. Setting DAILY_QUOTA to 0 turns the quota off.
+const DefaultDailyQuota = 20
+
+// DefaultQuotaFile is where the counters are kept between restarts
+const DefaultQuotaFile = "quota.json"
+
+// quotaStore counts each user's downloads for the current day and persists the
+// counters, so a restart doesn't hand everyone a fresh allowance
+type quotaStore struct {
+	mu       sync.Mutex
+	path     string
+	limit    int
+	location *time.Location
+	Day      string        `json:"day"`
+	Counts   map[int64]int `json:"counts"`
+}
+
+// quotas is configured from DAILY_QUOTA, QUOTA_FILE and QUOTA_TIMEZONE (an IANA
+// name such as "Asia/Tashkent"; midnight in that zone starts a new day)
+var quotas = loadQuotaStore()
+
+func loadQuotaStore() *quotaStore {
+	store := &quotaStore{
+		path:     os.Getenv("QUOTA_FILE"),
+		limit:    DefaultDailyQuota,
+		location: time.UTC,
+		Counts:   make(map[int64]int),
+	}
+	if store.path == "" {
+		store.path = DefaultQuotaFile
+	}
+	if limit, err := strconv.Atoi(os.Getenv("DAILY_QUOTA")); err == nil && limit >= 0 {
+		store.limit = limit
+	}
+	if name := os.Getenv("QUOTA_TIMEZONE"); name != "" {
+		if location, err := time.LoadLocation(name); err != nil {
+			log.Printf("Unknown QUOTA_TIMEZONE %q, using UTC: %v", name, err)
+		} else {
+			store.location = location
+		}
+	}
+
+	data, err := os.ReadFile(store.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Printf("Failed to read %s, starting with empty quotas: %v", store.path, err)
+	default:
+		if err := json.Unmarshal(data, store); err != nil {
+			log.Printf("Failed to parse %s, starting with empty quotas: %v", store.path, err)
+		}
+		if store.Counts == nil {
+			store.Counts = make(map[int64]int)
+		}
+	}
+	return store
+}
+
+// nextReset returns when the current quota day ends
+func (q *quotaStore) nextReset(now time.Time) time.Time {
+	local := now.In(q.location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, q.location)
+}
+
+// consume counts a download against the user's quota. It returns false, along
+// with when the quota resets, if the user has none left. Admins are exempt.
+func (q *quotaStore) consume(userID int64) (bool, time.Time) {
+	if q.limit == 0 || isAdmin(userID) {
+		return true, time.Time{}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if today := now.In(q.location).Format("2006-01-02"); q.Day != today {
+		q.Day = today
+		q.Counts = make(map[int64]int)
+	}
+
+	if q.Counts[userID] >= q.limit {
+		return false, q.nextReset(now)
+	}
+	q.Counts[userID]++
+	q.save()
+	return true, time.Time{}
+}
+
+// save writes the counters to a temp file and renames it into place, so a
+// crash mid-write can't leave a corrupt file. The caller holds q.mu.
+func (q *quotaStore) save() {
+	data, err := json.Marshal(q)
+	if err != nil {
+		log.Println("Failed to encode quotas:", err)
+		return
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Println("Failed to save quotas:", err)
+		return
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		log.Println("Failed to save quotas:", err)
+	}
+}
+
+// quotaExceededText tells the user their allowance and when it comes back
+func quotaExceededText(resetAt time.Time) string {
+	return fmt.Sprintf("🚫 You've used all %d downloads for today. Your quota resets at %s (in %s).",
+		quotas.limit, resetAt.Format("15:04 MST"), time.Until(resetAt).Round(time.Minute))
+}
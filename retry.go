@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Attempt remembers what a chat last tried to download so it can be re-run
+type Attempt struct {
+	Info    Download
+	Format  string
+	Quality string
+	// Key is the cache key the download runs under. Retries keep it, so they
+	// can't overlap each other or the download they repeat.
+	Key string
+}
+
+var (
+	lastAttemptsMu sync.Mutex
+	lastAttempts   = make(map[int64]Attempt)
+)
+
+func recordAttempt(chatID int64, attempt Attempt) {
+	lastAttemptsMu.Lock()
+	defer lastAttemptsMu.Unlock()
+	lastAttempts[chatID] = attempt
+}
+
+func getLastAttempt(chatID int64) (Attempt, bool) {
+	lastAttemptsMu.Lock()
+	defer lastAttemptsMu.Unlock()
+	attempt, ok := lastAttempts[chatID]
+	return attempt, ok
+}
+
+// clearLastAttempt forgets the chat's attempt once it has succeeded
+func clearLastAttempt(chatID int64) {
+	lastAttemptsMu.Lock()
+	defer lastAttemptsMu.Unlock()
+	delete(lastAttempts, chatID)
+}
+
+// sendFailure reports a failed download with a button to try it again
+func sendFailure(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	stats.DownloadsFailed.Add(1)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Retry", "retry:last"),
+		),
+	)
+	if _, err := sendWithRetry(bot, msg); err != nil {
+		log.Println("Failed to send failure message:", err)
+	}
+}
+
+// handleRetry re-runs the chat's last failed download, from either the /retry
+// command or the Retry button
+func handleRetry(bot *tgbotapi.BotAPI, runner Runner, chatID int64, user *tgbotapi.User) {
+	attempt, ok := getLastAttempt(chatID)
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "🤷 There's no failed download to retry."))
+		return
+	}
+
+	// A retry resumes the attempt's files, so two running at once would write
+	// over each other
+	if !urlCache.tryStart(attempt.Key) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⏳ That download is already running."))
+		return
+	}
+	if user != nil {
+		if ok, resetAt := quotas.consume(user.ID); !ok {
+			urlCache.finish(attempt.Key)
+			bot.Send(tgbotapi.NewMessage(chatID, quotaExceededText(resetAt)))
+			return
+		}
+	}
+
+	statusMsg, err := bot.Send(tgbotapi.NewMessage(chatID, "🔁 Retrying your last download..."))
+	if err != nil {
+		urlCache.finish(attempt.Key)
+		return
+	}
+
+	// Same file names as the failed attempt, so yt-dlp resumes instead of starting over
+	info := attempt.Info
+	info.Resume = true
+	startDownloadUnder(bot, runner, chatID, info, attempt.Format, attempt.Quality, statusMsg.MessageID, attempt.Key)
+}
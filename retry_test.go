@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRetryDoesNotOverlap(t *testing.T) {
+	previous := quotas
+	quotas = &quotaStore{
+		path:     filepath.Join(t.TempDir(), "quota.json"),
+		limit:    5,
+		location: time.UTC,
+		Counts:   make(map[int64]int),
+	}
+	t.Cleanup(func() { quotas = previous })
+
+	const chatID = 42
+	user := &tgbotapi.User{ID: 7}
+	key := getCacheKey(chatID, 1)
+	recordAttempt(chatID, Attempt{
+		Info:    Download{Platform: "Vimeo", URL: "https://vimeo.com/1", FileStamp: 1},
+		Format:  "video",
+		Quality: "best",
+		Key:     key,
+	})
+	t.Cleanup(func() { clearLastAttempt(chatID) })
+
+	// The first retry's download hangs until released
+	release := make(chan struct{})
+	runner := &fakeRunner{respond: func(call fakeCall) (string, error) {
+		<-release
+		return unsupportedURL(call)
+	}}
+	bot := newTestBot(t)
+
+	handleRetry(bot, runner, chatID, user)
+	// A double tap while it runs
+	handleRetry(bot, runner, chatID, user)
+
+	for len(runner.commands("yt-dlp")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	if !waitForDownloads(5 * time.Second) {
+		t.Fatal("the retried download didn't finish")
+	}
+
+	if calls := len(runner.commands("yt-dlp")); calls != 1 {
+		t.Errorf("yt-dlp ran %d times, want 1", calls)
+	}
+	if used := quotas.Counts[user.ID]; used != 1 {
+		t.Errorf("retries used %d downloads of the quota, want 1", used)
+	}
+	if urlCache.isActive(key) {
+		t.Error("the download is still marked as running")
+	}
+
+	// Once it has finished, retrying is allowed again
+	handleRetry(bot, runner, chatID, user)
+	if !waitForDownloads(5 * time.Second) {
+		t.Fatal("the second retry didn't finish")
+	}
+	if calls := len(runner.commands("yt-dlp")); calls != 2 {
+		t.Errorf("yt-dlp ran %d times after the second retry, want 2", calls)
+	}
+}
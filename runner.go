@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// Runner executes external programs such as yt-dlp, ffmpeg and ffprobe.
+// Handlers receive one instead of calling os/exec directly so the command
+// construction can be exercised without the real binaries.
+type Runner interface {
+	// Run executes the command and returns its standard output
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+	// Stream executes the command and hands every line it writes to stdout or
+	// stderr to onLine as it arrives, returning once the command has exited
+	Stream(ctx context.Context, onLine func(line string), name string, args ...string) error
+}
+
+// errStartFailed marks errors where a command could not be launched at all
+var errStartFailed = errors.New("failed to start command")
+
+// execRunner is the Runner backed by os/exec
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	configureProcessGroup(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errStartFailed, err)
+	}
+	children.add(cmd)
+	defer children.remove(cmd)
+
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// Keep stderr around for callers, as cmd.Output would
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+func (execRunner) Stream(ctx context.Context, onLine func(line string), name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	configureProcessGroup(cmd)
+
+	// yt-dlp reports progress and postprocessor results on stdout and errors on
+	// stderr, so both share one pipe and their lines arrive in order
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errStartFailed, err)
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %v", errStartFailed, err)
+	}
+	children.add(cmd)
+	defer children.remove(cmd)
+
+	// Drain the output before waiting, as Wait closes the pipe
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+
+	return cmd.Wait()
+}
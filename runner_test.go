@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeCall is one command a fakeRunner was asked to run
+type fakeCall struct {
+	name string
+	args []string
+}
+
+// fakeRunner records the commands it is given instead of running them. respond,
+// when set, supplies each call's output and error; otherwise calls succeed
+// without output.
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   []fakeCall
+	respond func(call fakeCall) (string, error)
+}
+
+func (r *fakeRunner) record(name string, args []string) (string, error) {
+	call := fakeCall{name, append([]string(nil), args...)}
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+
+	if r.respond == nil {
+		return "", nil
+	}
+	return r.respond(call)
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := r.record(name, args)
+	return []byte(output), err
+}
+
+func (r *fakeRunner) Stream(ctx context.Context, onLine func(line string), name string, args ...string) error {
+	output, err := r.record(name, args)
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line != "" {
+			onLine(line)
+		}
+	}
+	return err
+}
+
+// commands returns the arguments of every call to the named program, in order
+func (r *fakeRunner) commands(name string) [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result [][]string
+	for _, call := range r.calls {
+		if call.name == name {
+			result = append(result, call.args)
+		}
+	}
+	return result
+}
+
+// newTestBot returns a bot whose API calls all go to a local server that
+// accepts everything, so handlers can run without Telegram
+func newTestBot(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	bot := &tgbotapi.BotAPI{Token: "test", Client: server.Client(), Buffer: 100}
+	bot.SetAPIEndpoint(server.URL + "/bot%s/%s")
+	return bot
+}
+
+// flagValue returns the argument following the first occurrence of flag
+func flagValue(args []string, flag string) (string, bool) {
+	i := slices.Index(args, flag)
+	if i < 0 || i+1 >= len(args) {
+		return "", false
+	}
+	return args[i+1], true
+}
+
+// unsupportedURL makes yt-dlp fail the way it does for a link it can't handle,
+// which ends a download without any fallback attempts
+func unsupportedURL(call fakeCall) (string, error) {
+	return "ERROR: Unsupported URL: " + call.args[len(call.args)-1], errors.New("exit status 1")
+}
+
+func TestVideoDownloadArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      Download
+		quality   string
+		format    string
+		remux     bool
+		extractor string
+		flags     []string
+	}{
+		{
+			name:      "YouTube 720p",
+			info:      Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc"},
+			quality:   "720p",
+			format:    "22/136+bestaudio/bestvideo[height<=720]+bestaudio/best[height<=720]",
+			remux:     true,
+			extractor: "youtube:player_client=android",
+			flags:     []string{"--no-playlist"},
+		},
+		{
+			name:      "YouTube original",
+			info:      Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc"},
+			quality:   "original",
+			format:    "b",
+			extractor: "youtube:player_client=android",
+		},
+		{
+			name:    "TikTok default",
+			info:    Download{Platform: "TikTok", URL: "https://www.tiktok.com/@user/video/1"},
+			quality: "best",
+			format:  "best[ext=mp4]/best",
+			remux:   true,
+		},
+		{
+			name:    "TikTok without watermark",
+			info:    Download{Platform: "TikTok", URL: "https://www.tiktok.com/@user/video/1"},
+			quality: "nowm",
+			format: "best[format_id!^=download][format_note!*=watermark][ext=mp4]/" +
+				"best[format_id!^=download][format_note!*=watermark]/best[ext=mp4]/best",
+			remux: true,
+		},
+		{
+			name:    "Instagram medium",
+			info:    Download{Platform: "Instagram", URL: "https://www.instagram.com/reel/abc/"},
+			quality: "medium",
+			format:  "worst[ext=mp4]/worst",
+			remux:   true,
+			flags:   []string{"--no-check-certificate"},
+		},
+		{
+			name:    "Facebook 480p",
+			info:    Download{Platform: "Facebook", URL: "https://www.facebook.com/watch/?v=1"},
+			quality: "480p",
+			format:  "bestvideo[height<=480]+bestaudio/best[height<=480]",
+			remux:   true,
+			flags:   []string{"--no-check-certificate"},
+		},
+		{
+			name:    "other platform 1080p",
+			info:    Download{Platform: "Vimeo", URL: "https://vimeo.com/1"},
+			quality: "1080p",
+			format:  "bestvideo[height<=1080]+bestaudio/best[height<=1080]",
+			remux:   true,
+		},
+		{
+			name:    "resumed retry",
+			info:    Download{Platform: "Vimeo", URL: "https://vimeo.com/1", Resume: true},
+			quality: "best",
+			format:  "best",
+			remux:   true,
+			flags:   []string{"--continue"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{respond: unsupportedURL}
+			handleVideoDownload(newTestBot(t), runner, 1, tt.info, tt.quality, 1)
+
+			calls := runner.commands("yt-dlp")
+			if len(calls) != 1 {
+				t.Fatalf("yt-dlp ran %d times, want 1", len(calls))
+			}
+			args := calls[0]
+
+			if got := args[len(args)-1]; got != tt.info.URL {
+				t.Errorf("last argument = %q, want the URL %q", got, tt.info.URL)
+			}
+			if got, _ := flagValue(args, "-f"); got != tt.format {
+				t.Errorf("-f = %q, want %q", got, tt.format)
+			}
+			if _, remux := flagValue(args, "--remux-video"); remux != tt.remux {
+				t.Errorf("--remux-video present = %v, want %v", remux, tt.remux)
+			}
+			if got, _ := flagValue(args, "--extractor-args"); got != tt.extractor {
+				t.Errorf("--extractor-args = %q, want %q", got, tt.extractor)
+			}
+			for _, flag := range tt.flags {
+				if !slices.Contains(args, flag) {
+					t.Errorf("missing %s in %q", flag, args)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildAudioArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    Download
+		format  string
+		flags   []string
+		without []string
+	}{
+		{
+			name:    "YouTube",
+			info:    Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc"},
+			without: []string{"-f", "--no-check-certificate", "--embed-metadata"},
+		},
+		{
+			name:   "YouTube audio language",
+			info:   Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc", AudioLanguage: "de"},
+			format: "bestaudio[language=de]/bestaudio/best",
+		},
+		{
+			name:  "YouTube Music",
+			info:  Download{Platform: "YouTube", URL: "https://music.youtube.com/watch?v=abc"},
+			flags: []string{"--embed-metadata", "--embed-thumbnail"},
+		},
+		{
+			name:  "Instagram",
+			info:  Download{Platform: "Instagram", URL: "https://www.instagram.com/reel/abc/"},
+			flags: []string{"--no-check-certificate"},
+		},
+		{
+			name:  "Facebook",
+			info:  Download{Platform: "Facebook", URL: "https://www.facebook.com/watch/?v=1"},
+			flags: []string{"--no-check-certificate"},
+		},
+		{
+			name:    "TikTok",
+			info:    Download{Platform: "TikTok", URL: "https://www.tiktok.com/@user/video/1"},
+			without: []string{"--no-check-certificate"},
+		},
+		{
+			name:  "resumed retry",
+			info:  Download{Platform: "Vimeo", URL: "https://vimeo.com/1", Resume: true},
+			flags: []string{"--continue"},
+		},
+		{
+			name:  "metadata sidecar",
+			info:  Download{Platform: "Vimeo", URL: "https://vimeo.com/1", WithInfoJSON: true},
+			flags: []string{"--write-info-json", "infojson:audio_meta.%(ext)s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := buildAudioArgs(tt.info, "audio.%(ext)s")
+
+			for flag, want := range map[string]string{"--audio-format": "mp3", "-o": "audio.%(ext)s", "-S": "abr"} {
+				if got, _ := flagValue(args, flag); got != want {
+					t.Errorf("%s = %q, want %q", flag, got, want)
+				}
+			}
+			if !slices.Contains(args, "-x") {
+				t.Errorf("missing -x in %q", args)
+			}
+			if got, _ := flagValue(args, "-f"); got != tt.format {
+				t.Errorf("-f = %q, want %q", got, tt.format)
+			}
+			for _, flag := range tt.flags {
+				if !slices.Contains(args, flag) {
+					t.Errorf("missing %s in %q", flag, args)
+				}
+			}
+			for _, flag := range tt.without {
+				if slices.Contains(args, flag) {
+					t.Errorf("unexpected %s in %q", flag, args)
+				}
+			}
+		})
+	}
+}
+
+// yt-dlp splits its output between the two streams, so Stream has to read both
+func TestExecRunnerStreamReadsStdoutAndStderr(t *testing.T) {
+	var lines []string
+	err := execRunner{}.Stream(context.Background(), func(line string) {
+		lines = append(lines, line)
+	}, "sh", "-c", "echo '[SponsorBlock] Found 2 segments'; echo 'ERROR: oops' >&2; echo 100/100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"[SponsorBlock] Found 2 segments", "ERROR: oops", "100/100"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("Stream passed on %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Limits for retrying requests that Telegram rejected for transient reasons
+const (
+	MaxSendAttempts  = 4
+	InitialSendDelay = time.Second
+)
+
+// transientDelay reports whether err is worth retrying and, if Telegram said how
+// long to wait (retry_after), for how long
+func transientDelay(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.RetryAfter > 0:
+			return time.Duration(apiErr.RetryAfter) * time.Second, true
+		case apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError:
+			return 0, true
+		default:
+			return 0, false
+		}
+	}
+
+	// Timeouts and dropped connections never reached Telegram's API logic
+	var netErr net.Error
+	return 0, errors.As(err, &netErr)
+}
+
+// isFileTooBig reports whether Telegram refused an upload for its size, which
+// can happen even after our own size check passed
+func isFileTooBig(err error) bool {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+	lower := strings.ToLower(err.Error())
+	return strings.Contains(lower, "file is too big") || strings.Contains(lower, "request entity too large")
+}
+
+// isNotModified reports whether Telegram refused an edit because the message
+// already says exactly that, which is harmless
+func isNotModified(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "message is not modified")
+}
+
+// retryTransient runs op until it succeeds, fails permanently or runs out of
+// attempts, backing off exponentially in between. Readers being uploaded are
+// rewound before each new attempt, since the failed one consumed them.
+func retryTransient(op func() error, rewind ...io.Seeker) error {
+	delay := InitialSendDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		wait, transient := transientDelay(err)
+		if !transient || attempt == MaxSendAttempts {
+			return err
+		}
+		if wait == 0 {
+			wait = delay
+			delay *= 2
+		}
+		log.Printf("Telegram request failed (attempt %d/%d), retrying in %s: %v", attempt, MaxSendAttempts, wait, err)
+		time.Sleep(wait)
+
+		for _, seeker := range rewind {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendWithRetry sends c, retrying on transient Telegram errors. Any file being
+// streamed from disk has to be passed in rewind so it can be re-sent.
+func sendWithRetry(bot *tgbotapi.BotAPI, c tgbotapi.Chattable, rewind ...io.Seeker) (tgbotapi.Message, error) {
+	var message tgbotapi.Message
+	err := retryTransient(func() error {
+		var err error
+		message, err = bot.Send(c)
+		return err
+	}, rewind...)
+	return message, err
+}
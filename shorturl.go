@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// Limits for resolving short links
+const (
+	ShortURLTimeout      = 5 * time.Second
+	MaxShortURLRedirects = 5
+)
+
+// shortURLHosts are link shorteners and share domains that hide the real target
+var shortURLHosts = []string{
+	"youtu.be",
+	"fb.watch",
+	"vm.tiktok.com",
+	"vt.tiktok.com",
+	"instagr.am",
+	"pin.it",
+	"bit.ly",
+	"t.co",
+	"tinyurl.com",
+	"goo.gl",
+	"ow.ly",
+	"is.gd",
+}
+
+// shortURLClient follows shortener redirects. The links come from users, so
+// like direct links it only connects to public addresses.
+var shortURLClient = &http.Client{
+	Timeout:       ShortURLTimeout,
+	Transport:     publicTransport,
+	CheckRedirect: checkPublicRedirect,
+}
+
+// isShortURL reports whether the link points at a known shortener
+func isShortURL(rawURL string) bool {
+	parsed, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	for _, short := range shortURLHosts {
+		if host == short {
+			return true
+		}
+	}
+	return false
+}
+
+// expandURL follows redirects to find where a short link really points
+func expandURL(rawURL string) (string, error) {
+	resp, err := shortURLClient.Head(rawURL)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		// Some shorteners only redirect GET requests
+		resp.Body.Close()
+		resp, err = shortURLClient.Get(rawURL)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return resp.Request.URL.String(), nil
+}
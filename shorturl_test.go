@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// A short link that leads into the bot's own network must not be followed
+func TestExpandURLRefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	internal := countingServer(t, &hits, httptest.NewServer)
+
+	if expanded, err := expandURL(internal.URL + "/abc"); err == nil {
+		t.Errorf("a short link on a private address expanded to %q", expanded)
+	}
+	if hits.Load() != 0 {
+		t.Error("expanding the link reached a private address")
+	}
+}
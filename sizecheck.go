@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SizeWarningRatio is how close to MaxFileSize an estimate must get before the
+// user is asked to confirm the download
+const SizeWarningRatio = 0.9
+
+// estimatedSize returns the exact, approximate or bitrate-derived size of a format
+func estimatedSize(f VideoFormat, duration float64) int64 {
+	if size := f.Size(); size > 0 {
+		return size
+	}
+	if f.TBR > 0 && duration > 0 {
+		return int64(f.TBR * 1000 / 8 * duration)
+	}
+	return 0
+}
+
+// estimateDownloadSize guesses how big a download will be from the formats yt-dlp
+// reported. It returns 0 when there isn't enough information to tell.
+func estimateDownloadSize(info Download, format, quality string) int64 {
+	meta := info.Meta
+	if meta == nil {
+		return 0
+	}
+
+	var bestAudio, bestVideo, bestSingle, smallestSingle int64
+	maxHeight, limited := parseHeight(quality)
+	bestVideoHeight := 0
+	for _, f := range meta.Formats {
+		size := estimatedSize(f, meta.Duration)
+		if size == 0 {
+			continue
+		}
+		switch {
+		case f.HasAudio() && !f.HasVideo():
+			if size > bestAudio {
+				bestAudio = size
+			}
+		case f.HasVideo():
+			if limited && f.Height > maxHeight {
+				continue
+			}
+			if f.HasAudio() && size > bestSingle {
+				bestSingle = size
+			}
+			if f.HasAudio() && (smallestSingle == 0 || size < smallestSingle) {
+				smallestSingle = size
+			}
+			if f.Height > bestVideoHeight || (f.Height == bestVideoHeight && size > bestVideo) {
+				bestVideoHeight = f.Height
+				bestVideo = size
+			}
+		}
+	}
+
+	switch {
+	case format == "audio":
+		return bestAudio
+	case format != "video":
+		return 0
+	case quality == "smallest":
+		return smallestSingle
+	case quality == "medium":
+		// Medium picks the worst single file, so it's sized by the smallest one
+		return smallestSingle
+	case quality == "original":
+		// The best single file as published
+		return bestSingle
+	case bestVideo > 0 && bestAudio > 0:
+		return bestVideo + bestAudio
+	default:
+		return bestSingle
+	}
+}
+
+// needsSizeConfirmation reports whether the estimate is close enough to the
+// limit to ask the user first
+func needsSizeConfirmation(estimate int64) bool {
+	return estimate > 0 && float64(estimate) >= SizeWarningRatio*MaxFileSize
+}
+
+func sizeConfirmationText(info Download, quality string, estimate int64) string {
+	return buildMessage(info.Title, func(title string) string {
+		return fmt.Sprintf("⚠️ "+bold("This may exceed the size limit")+"\n\n%s\n\n"+
+			"The %s download is estimated at %.0f MB, and Telegram only accepts up to %d MB.\n\n"+
+			"Download anyway, or pick a lower quality?",
+			title, quality, float64(estimate)/1048576, MaxFileSize/1048576)
+	})
+}
+
+func createSizeConfirmKeyboard(format, quality string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Download anyway", "confirm:"+format+"|"+quality),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Choose another quality", "menu:formats"),
+		),
+	)
+}
+
+// parseConfirmation splits the "format|quality" payload of a confirm button
+func parseConfirmation(payload string) (format, quality string, ok bool) {
+	format, quality, ok = strings.Cut(payload, "|")
+	return
+}
+
+// bestUnderLimit picks the highest resolution whose estimated size fits within
+// MaxFileSize. When nothing fits it returns the smallest one instead, with fits
+// set to false. ok is false when no format has a usable size estimate.
+func bestUnderLimit(info Download) (quality string, estimate int64, fits, ok bool) {
+	return bestUnderSize(info, MaxFileSize)
+}
+
+// bestUnderSize is bestUnderLimit for any size limit, such as one the user typed
+func bestUnderSize(info Download, limit int64) (quality string, estimate int64, fits, ok bool) {
+	var smallest int64
+	smallestQuality := ""
+	for _, height := range availableHeights(info.Meta) {
+		candidate := fmt.Sprintf("%dp", height)
+		size := estimateDownloadSize(info, "video", candidate)
+		if size == 0 {
+			continue
+		}
+		// Heights are sorted, so the last fitting one is the best
+		if size <= limit {
+			quality, estimate, fits = candidate, size, true
+		}
+		if smallestQuality == "" || size < smallest {
+			smallest, smallestQuality = size, candidate
+		}
+	}
+
+	switch {
+	case fits:
+		return quality, estimate, true, true
+	case smallestQuality != "":
+		return smallestQuality, smallest, false, true
+	default:
+		return "", 0, false, false
+	}
+}
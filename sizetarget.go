@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MinSizeTargetMB is the smallest size a user may ask for with "max <size>"
+const MinSizeTargetMB = 1
+
+// sizeTargetPattern matches replies such as "max 20MB", "max 20 mb" or "max 1.5gb"
+var sizeTargetPattern = regexp.MustCompile(`(?i)^\s*max\s+(\d+(?:\.\d+)?)\s*(mb|m|gb|g)?\s*$`)
+
+const sizeTargetUsage = "✍️ Reply to a download prompt with a size to get the best quality that fits, for example: max 20MB"
+
+// isSizeTarget reports whether a message is an attempt at a "max <size>" reply
+func isSizeTarget(text string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(text)), "max ")
+}
+
+// parseSizeTarget reads a "max <size>" reply into bytes. Megabytes are assumed
+// without a unit, and the size has to be between MinSizeTargetMB and what
+// Telegram accepts.
+func parseSizeTarget(text string) (int64, error) {
+	match := sizeTargetPattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, fmt.Errorf("⚠️ That doesn't look like a size. %s", sizeTargetUsage)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("⚠️ That doesn't look like a size. %s", sizeTargetUsage)
+	}
+	if unit := strings.ToLower(match[2]); unit == "gb" || unit == "g" {
+		value *= 1024
+	}
+	maxMB := float64(MaxFileSize / 1048576)
+	if value < MinSizeTargetMB || value > maxMB {
+		return 0, fmt.Errorf("⚠️ The size must be between %d and %.0f MB.", MinSizeTargetMB, maxMB)
+	}
+	return int64(value * 1048576), nil
+}
+
+// handleSizeTarget starts the download for the prompt the user replied to, in
+// the best quality whose estimated size fits under the size they gave
+func handleSizeTarget(bot *tgbotapi.BotAPI, runner Runner, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	promptID := message.ReplyToMessage.MessageID
+	cacheKey := getCacheKey(chatID, promptID)
+
+	info, ok := urlCache.get(cacheKey)
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, sizeTargetUsage))
+		return
+	}
+	if urlCache.isActive(cacheKey) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⏳ That download is already running."))
+		return
+	}
+
+	limit, err := parseSizeTarget(message.Text)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+
+	quality, estimate, fits, ok := bestUnderSize(info, limit)
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "🤷 The file sizes for this video are unknown, please pick a quality from the buttons."))
+		return
+	}
+	if !fits {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"⚠️ No quality fits under %.0f MB. Downloading the smallest one (%s, about %.0f MB).",
+			float64(limit)/1048576, quality, float64(estimate)/1048576)))
+	}
+
+	if !urlCache.tryStart(cacheKey) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⏳ That download is already running."))
+		return
+	}
+	if message.From != nil {
+		if ok, resetAt := quotas.consume(message.From.ID); !ok {
+			urlCache.finish(cacheKey)
+			bot.Send(tgbotapi.NewMessage(chatID, quotaExceededText(resetAt)))
+			return
+		}
+	}
+
+	info.IsAudio = false
+	urlCache.set(cacheKey, info)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, promptID,
+		buildMessage(info.Title, func(title string) string {
+			return fmt.Sprintf("⏳ "+bold("Processing %s download")+"\n\n%s\n\n0%% complete...", escapeText(strategyLabel(info, quality)), title)
+		}),
+	)
+	editMsg.ParseMode = parseMode
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{}
+	sendWithRetry(bot, editMsg)
+
+	// The prompt doubles as the status message, as it does for button presses
+	startDownload(bot, runner, chatID, info, "video", quality, promptID)
+}
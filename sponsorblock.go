@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultSponsorBlockCategories are cut when SPONSORBLOCK_CATEGORIES is unset
+const DefaultSponsorBlockCategories = "sponsor,selfpromo"
+
+// sponsorBlockCategories is read from SPONSORBLOCK_CATEGORIES at startup
+var sponsorBlockCategories = loadSponsorBlockCategories()
+
+func loadSponsorBlockCategories() string {
+	if value := strings.ReplaceAll(os.Getenv("SPONSORBLOCK_CATEGORIES"), " ", ""); value != "" {
+		return value
+	}
+	return DefaultSponsorBlockCategories
+}
+
+// sponsorSegmentsPattern matches yt-dlp's "Found 3 segments in the SponsorBlock database"
+var sponsorSegmentsPattern = regexp.MustCompile(`\[SponsorBlock\] Found (\d+) segments?`)
+
+// sponsorBlockArgs cuts the configured segment categories when the user opted in.
+// Cutting re-encodes around each cut, so it makes the download slower.
+func sponsorBlockArgs(info Download) []string {
+	if !info.SkipSponsors {
+		return nil
+	}
+	return []string{"--sponsorblock-remove", sponsorBlockCategories}
+}
+
+// sponsorBlockNote describes, for the caption, what SponsorBlock did according
+// to yt-dlp's output
+func sponsorBlockNote(info Download, output string) string {
+	if !info.SkipSponsors {
+		return ""
+	}
+	if match := sponsorSegmentsPattern.FindStringSubmatch(output); match != nil && match[1] != "0" {
+		return "\n▫️ Sponsor segments removed: " + match[1]
+	}
+	if strings.Contains(output, "No matching segments were found") {
+		return "\n▫️ No sponsor segments were found for this video"
+	}
+	return "\n▫️ SponsorBlock data wasn't available, nothing was removed"
+}
+
+// createSponsorBlockRow is the on/off switch for cutting sponsor segments
+func createSponsorBlockRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if info.Platform != "YouTube" {
+		return nil
+	}
+	label := "⏭ Skip sponsors: off"
+	if info.SkipSponsors {
+		label = "⏭ Skip sponsors: on ✅ (slower)"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "sponsorblock:toggle"))
+}
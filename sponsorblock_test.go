@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestSponsorBlockNote(t *testing.T) {
+	// Lines yt-dlp prints to stdout after the download finishes
+	const (
+		found = "1048576/2097152\n2097152/2097152\n" +
+			"[SponsorBlock] Fetching SponsorBlock segments\n" +
+			"[SponsorBlock] Found 3 segments in the SponsorBlock database\n" +
+			"[ModifyChapters] Removing chapters from video.mp4\n"
+		single   = "[SponsorBlock] Found 1 segment in the SponsorBlock database\n"
+		notFound = "[SponsorBlock] Fetching SponsorBlock segments\n" +
+			"[SponsorBlock] No matching segments were found in the SponsorBlock database\n"
+		unavailable = "WARNING: Unable to communicate with SponsorBlock API: HTTP Error 503\n"
+	)
+
+	tests := []struct {
+		name   string
+		skip   bool
+		output string
+		want   string
+	}{
+		{"segments removed", true, found, "\n▫️ Sponsor segments removed: 3"},
+		{"one segment", true, single, "\n▫️ Sponsor segments removed: 1"},
+		{"no segments", true, notFound, "\n▫️ No sponsor segments were found for this video"},
+		{"API down", true, unavailable, "\n▫️ SponsorBlock data wasn't available, nothing was removed"},
+		{"not asked for", false, found, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc", SkipSponsors: tt.skip}
+
+			// The note is built from what the download run collected
+			runner := &fakeRunner{respond: func(call fakeCall) (string, error) { return tt.output, nil }}
+			output, err := runDownload(newTestBot(t), runner, 1, 1, info, sponsorBlockArgs(info), "best")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := sponsorBlockNote(info, output); got != tt.want {
+				t.Errorf("sponsorBlockNote = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,3 +1,3 @@
 #!/bin/bash
-pip install -U yt-dlp
-go run main.go
+pip install -U yt-dlp gallery-dl
+go run .
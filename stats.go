@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Stats holds the bot's counters. Every field is updated atomically because
+// download goroutines increment them while /stats reads them concurrently.
+type Stats struct {
+	StartedAt          time.Time
+	LinksReceived      atomic.Int64
+	DownloadsStarted   atomic.Int64
+	DownloadsSucceeded atomic.Int64
+	DownloadsFailed    atomic.Int64
+	ActiveDownloads    atomic.Int64
+}
+
+var stats = &Stats{StartedAt: time.Now()}
+
+// downloadSucceeded records a finished download and forgets it for /retry
+func downloadSucceeded(chatID int64) {
+	clearLastAttempt(chatID)
+	stats.DownloadsSucceeded.Add(1)
+}
+
+// statsText renders a snapshot of the counters
+func statsText() string {
+	return fmt.Sprintf("📊 "+bold("Bot statistics")+"\n\n"+
+		"▫️ Uptime: %s\n"+
+		"▫️ Links received: %d\n"+
+		"▫️ Downloads started: %d\n"+
+		"▫️ Succeeded: %d\n"+
+		"▫️ Failed: %d\n"+
+		"▫️ In progress: %d",
+		time.Since(stats.StartedAt).Round(time.Second),
+		stats.LinksReceived.Load(),
+		stats.DownloadsStarted.Load(),
+		stats.DownloadsSucceeded.Load(),
+		stats.DownloadsFailed.Load(),
+		stats.ActiveDownloads.Load())
+}
+
+// handleStats shows the counters to admins
+func handleStats(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if message.From == nil || !isAdmin(message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "🔒 This command is only available to admins."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, statsText())
+	msg.ParseMode = parseMode
+	bot.Send(msg)
+}
+
+// handlePing answers liveness checks. It runs on the update loop and never
+// waits for downloads, so it responds even when every worker is busy.
+func handlePing(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	latency := time.Since(message.Time()).Round(time.Millisecond)
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("🏓 pong\n▫️ Latency: %s\n▫️ Uptime: %s",
+		latency, time.Since(stats.StartedAt).Round(time.Second))))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: the counters are bumped by download goroutines while /stats
+// renders them
+func TestStatsConcurrentUpdates(t *testing.T) {
+	previous := stats
+	stats = &Stats{StartedAt: time.Now()}
+	t.Cleanup(func() { stats = previous })
+
+	const workers, perWorker = 50, 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				stats.LinksReceived.Add(1)
+				stats.DownloadsStarted.Add(1)
+				stats.ActiveDownloads.Add(1)
+				if j%2 == 0 {
+					downloadSucceeded(int64(j))
+				} else {
+					stats.DownloadsFailed.Add(1)
+				}
+				stats.ActiveDownloads.Add(-1)
+			}
+		}()
+	}
+
+	// /stats renders while the workers are still counting
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if text := statsText(); !strings.Contains(text, "Links received") {
+				t.Errorf("unexpected stats text %q", text)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	<-done
+
+	total := int64(workers * perWorker)
+	checks := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{"LinksReceived", stats.LinksReceived.Load(), total},
+		{"DownloadsStarted", stats.DownloadsStarted.Load(), total},
+		{"DownloadsSucceeded", stats.DownloadsSucceeded.Load(), total / 2},
+		{"DownloadsFailed", stats.DownloadsFailed.Load(), total / 2},
+		{"ActiveDownloads", stats.ActiveDownloads.Load(), 0},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if text := statsText(); !strings.Contains(text, "Succeeded: 5000") {
+		t.Errorf("stats text doesn't show the final totals:\n%s", text)
+	}
+}
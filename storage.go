@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultStorageChannelMaxMB is the largest file sent through the storage
+// channel when STORAGE_CHANNEL_MAX_MB is unset. Telegram only accepts uploads
+// this large from bots running against a local Bot API server.
+const DefaultStorageChannelMaxMB = 2000
+
+// storageChannelID is a channel the bot admins, from STORAGE_CHANNEL_ID. Files
+// too large for the chat are uploaded there and copied to the user. 0 means off.
+var storageChannelID = loadStorageChannelID()
+
+// storageChannelMaxSize is read from STORAGE_CHANNEL_MAX_MB at startup
+var storageChannelMaxSize = loadStorageChannelMaxSize()
+
+func loadStorageChannelID() int64 {
+	id, err := strconv.ParseInt(os.Getenv("STORAGE_CHANNEL_ID"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func loadStorageChannelMaxSize() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("STORAGE_CHANNEL_MAX_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = DefaultStorageChannelMaxMB
+	}
+	return int64(megabytes) * 1024 * 1024
+}
+
+// sendOversized hands over a file too large for the chat: through the storage
+// channel when it fits there, otherwise as a download link. It reports false
+// when neither is available, so the caller can explain the limit.
+func sendOversized(bot *tgbotapi.BotAPI, chatID int64, info Download, path string, sizeMB float64) bool {
+	return sendViaStorageChannel(bot, chatID, info, path, sizeMB) || sendDownloadLink(bot, chatID, info, path, sizeMB)
+}
+
+// sendViaStorageChannel uploads an oversized file to the storage channel and
+// copies it into the chat, or links to it when the copy fails and the channel
+// is public. It reports false, leaving the file alone, when no channel is
+// configured, the file is too big even for the channel, or the upload fails.
+func sendViaStorageChannel(bot *tgbotapi.BotAPI, chatID int64, info Download, path string, sizeMB float64) bool {
+	stat, err := os.Stat(path)
+	if storageChannelID == 0 || err != nil || stat.Size() > storageChannelMaxSize {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		jobLog(info).Println("Failed to open file for the storage channel:", err)
+		return false
+	}
+	defer file.Close()
+
+	document := tgbotapi.NewDocument(storageChannelID, tgbotapi.FileReader{Name: uploadFilename(info, path), Reader: file})
+	document.Caption = buildCaption(info.Title, func(title string) string {
+		return fmt.Sprintf("📦 "+bold("%s")+" - %s\n▫️ Size: %.1f MB", info.Platform, title, sizeMB)
+	})
+	document.ParseMode = parseMode
+	stored, err := sendWithRetry(bot, document, file)
+	if err != nil {
+		jobLog(info).Println("Failed to upload to the storage channel:", err)
+		return false
+	}
+	jobLog(info).Printf("Stored %.1f MB file as message %d in the storage channel", sizeMB, stored.MessageID)
+
+	_, err = bot.Request(tgbotapi.NewCopyMessage(chatID, storageChannelID, stored.MessageID))
+	if err == nil {
+		downloadSucceeded(chatID)
+		return true
+	}
+	jobLog(info).Println("Failed to copy from the storage channel:", err)
+
+	// Public channels can at least be linked to
+	if stored.Chat == nil || stored.Chat.UserName == "" {
+		return false
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📦 This file (%.1f MB) is too large to send here, so it was posted to the bot's channel instead.", sizeMB))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("📺 Open",
+			fmt.Sprintf("https://t.me/%s/%d", stored.Chat.UserName, stored.MessageID))),
+	)
+	if _, err := sendWithRetry(bot, msg); err != nil {
+		jobLog(info).Println("Failed to send storage channel link:", err)
+		return false
+	}
+	downloadSucceeded(chatID)
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Download strategies: "fast" takes a single progressive file, "best" merges the
+// best separate video and audio streams with ffmpeg
+const (
+	StrategyFast = "fast"
+	StrategyBest = "best"
+)
+
+// FastStrategyMaxDuration is the longest video, in seconds, that defaults to the
+// fast strategy. Progressive files top out at lower resolutions, which matters
+// less for short clips.
+const FastStrategyMaxDuration = 5 * 60
+
+// downloadStrategy returns the strategy the user picked, or the default for the
+// video's length
+func downloadStrategy(info Download) string {
+	if info.Strategy != "" {
+		return info.Strategy
+	}
+	if info.Meta != nil && info.Meta.Duration > 0 && info.Meta.Duration <= FastStrategyMaxDuration {
+		return StrategyFast
+	}
+	return StrategyBest
+}
+
+// qualityStrategy returns the strategy a download in the given quality uses.
+// Short videos only default to fast when a progressive file exists at the height
+// the merging strategy would deliver; otherwise fast would quietly fall back to
+// a lower resolution.
+func qualityStrategy(info Download, quality string) string {
+	strategy := downloadStrategy(info)
+	if info.Strategy == "" && strategy == StrategyFast && !hasProgressiveAt(info.Meta, quality) {
+		return StrategyBest
+	}
+	return strategy
+}
+
+// hasProgressiveAt reports whether the video is offered as a single file with
+// both video and audio at the highest height the quality allows
+func hasProgressiveAt(meta *VideoMetadata, quality string) bool {
+	limit, ok := parseHeight(quality)
+	if !ok && quality != "best" {
+		return false
+	}
+	target := 0
+	for _, height := range availableHeights(meta) {
+		if !ok || height <= limit {
+			target = height
+		}
+	}
+	if target == 0 {
+		return false
+	}
+	for _, f := range meta.Formats {
+		if f.HasVideo() && f.HasAudio() && f.Height == target {
+			return true
+		}
+	}
+	return false
+}
+
+// progressiveFormatCode is the single-file selector used by the fast strategy.
+// Qualities that already pick a specific kind of file are left alone.
+func progressiveFormatCode(quality string) (string, bool) {
+	if height, ok := parseHeight(quality); ok {
+		return fmt.Sprintf("best[height<=%d][ext=mp4]/best[height<=%d]/best[ext=mp4]/best", height, height), true
+	}
+	if quality == "best" {
+		return "best[ext=mp4]/best", true
+	}
+	return "", false
+}
+
+// createStrategyRow lets the user switch between the fast and best strategies
+func createStrategyRow(info Download) []tgbotapi.InlineKeyboardButton {
+	fast, best := "⚡ Fast", "💎 Best"
+	if downloadStrategy(info) == StrategyFast {
+		fast += " ✅"
+	} else {
+		best += " ✅"
+	}
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fast, "strategy:"+StrategyFast),
+		tgbotapi.NewInlineKeyboardButtonData(best, "strategy:"+StrategyBest),
+	)
+}
+
+// strategyLabel names a video quality together with the strategy used for it,
+// for the status message
+func strategyLabel(info Download, quality string) string {
+	if _, ok := progressiveFormatCode(quality); !ok || info.AudioLanguage != "" {
+		return quality
+	}
+	return fmt.Sprintf("%s, %s", quality, qualityStrategy(info, quality))
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestQualityStrategy(t *testing.T) {
+	// A short clip offered progressively at 360p only, like most of YouTube
+	short := &VideoMetadata{Duration: 60, Formats: []VideoFormat{
+		{FormatID: "18", Height: 360, VCodec: "avc1", ACodec: "mp4a"},
+		{FormatID: "136", Height: 720, VCodec: "avc1", ACodec: "none"},
+		{FormatID: "140", VCodec: "none", ACodec: "mp4a"},
+	}}
+	// The same clip with a 720p progressive file as well
+	progressive720 := &VideoMetadata{Duration: 60, Formats: append([]VideoFormat{
+		{FormatID: "22", Height: 720, VCodec: "avc1", ACodec: "mp4a"},
+	}, short.Formats...)}
+	long := &VideoMetadata{Duration: 3600, Formats: progressive720.Formats}
+
+	tests := []struct {
+		name    string
+		info    Download
+		quality string
+		want    string
+	}{
+		{"progressive at the height", Download{Meta: short}, "360p", StrategyFast},
+		{"progressive only below the height", Download{Meta: short}, "720p", StrategyBest},
+		{"progressive at the highest height", Download{Meta: progressive720}, "best", StrategyFast},
+		{"highest height only merged", Download{Meta: short}, "best", StrategyBest},
+		{"limit above every height", Download{Meta: progressive720}, "1080p", StrategyFast},
+		{"long video", Download{Meta: long}, "720p", StrategyBest},
+		{"no metadata", Download{}, "720p", StrategyBest},
+		{"picked by the user", Download{Meta: short, Strategy: StrategyFast}, "720p", StrategyFast},
+	}
+
+	for _, tt := range tests {
+		if got := qualityStrategy(tt.info, tt.quality); got != tt.want {
+			t.Errorf("%s: qualityStrategy(%q) = %q, want %q", tt.name, tt.quality, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// createSubtitlesRow offers the subtitle file itself when the video has any
+func createSubtitlesRow(info Download) []tgbotapi.InlineKeyboardButton {
+	if len(transcriptLanguages(info.Meta)) == 0 {
+		return nil
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💬 Subtitles (.srt)", "subtitles:pick"))
+}
+
+// handleSubtitleDownload fetches the subtitles in the given language, converted
+// to SubRip, and sends the .srt file as a document. Nothing but the subtitles is
+// downloaded.
+func handleSubtitleDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, language string, statusMsgID int) {
+	prefix := filePrefix("subtitles", fileStamp(info))
+
+	ytdlpArgs := []string{
+		"--skip-download",
+		"--write-subs",
+		"--write-auto-subs",
+		"--convert-subs", "srt",
+		"--sub-langs", language,
+		"-o", prefix + ".%(ext)s",
+		"--no-playlist",
+	}
+
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, "subtitles")
+
+	subtitleFiles, _ := filepath.Glob(prefix + ".*")
+	for _, file := range subtitleFiles {
+		defer os.Remove(file)
+	}
+
+	if err != nil {
+		reportDownloadFailure(bot, chatID, info, "subtitle download", err, output)
+		return
+	}
+
+	var srtFile string
+	for _, file := range subtitleFiles {
+		if strings.HasSuffix(file, ".srt") {
+			srtFile = file
+			break
+		}
+	}
+	if srtFile == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "💬 No subtitles are available for this video in that language."))
+		return
+	}
+
+	srt, err := os.ReadFile(srtFile)
+	if err != nil {
+		jobLog(info).Println("Failed to read subtitles:", err)
+		sendFailure(bot, chatID, "❌ Failed to read the subtitles.")
+		return
+	}
+	if strings.TrimSpace(string(srt)) == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "💬 The subtitles for this video are empty."))
+		return
+	}
+
+	caption := buildCaption(info.Title, func(title string) string {
+		return fmt.Sprintf("💬 "+bold("Subtitles")+" - %s\n▫️ Language: %s", title, escapeText(language))
+	})
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("%s (%s).srt", sanitizeFilename(info.Title), language),
+		Bytes: srt,
+	})
+	document.Caption = caption
+	document.ParseMode = parseMode
+	if _, err := sendWithRetry(bot, document); err != nil {
+		jobLog(info).Println("Failed to send subtitles:", err)
+		sendFailure(bot, chatID, "❌ Failed to send the subtitles.")
+		return
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, statusMsgID)); err != nil {
+		jobLog(info).Println("Failed to delete the status message:", err)
+	}
+	downloadSucceeded(chatID)
+}
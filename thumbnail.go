@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultThumbnailFrameSeconds is how far into a video the preview frame is
+// taken when THUMBNAIL_FRAME_SECONDS is unset. The very first frame is often
+// black, a fade-in or a title card.
+const DefaultThumbnailFrameSeconds = 3
+
+// thumbnailFrameSeconds is read from THUMBNAIL_FRAME_SECONDS at startup
+var thumbnailFrameSeconds = loadThumbnailFrameSeconds()
+
+func loadThumbnailFrameSeconds() int {
+	seconds, err := strconv.Atoi(os.Getenv("THUMBNAIL_FRAME_SECONDS"))
+	if err != nil || seconds < 0 {
+		return DefaultThumbnailFrameSeconds
+	}
+	return seconds
+}
+
+// thumbnailOffset picks the preview frame's timestamp, moving it to the middle
+// of videos too short to reach it
+func thumbnailOffset(duration int) int {
+	if duration > 0 && thumbnailFrameSeconds >= duration {
+		return duration / 2
+	}
+	return thumbnailFrameSeconds
+}
+
+// prepareThumbnail converts a thumbnail (local file or remote URL) into a JPEG
+// that satisfies Telegram's thumbnail rules: at most 320px on each side and small
+// enough to stay under the 200KB cap. For a video, offset picks the frame, in
+// seconds from the start.
+func prepareThumbnail(runner Runner, source, output string, offset int) error {
+	args := []string{"-y", "-loglevel", "error"}
+	if offset > 0 {
+		// Seeking before the input is fast, it jumps straight to the nearest keyframe
+		args = append(args, "-ss", strconv.Itoa(offset))
+	}
+	args = append(args,
+		"-i", source,
+		"-vf", "scale=320:320:force_original_aspect_ratio=decrease",
+		"-frames:v", "1",
+		"-q:v", "5",
+		output,
+	)
+	if _, err := runner.Run(context.Background(), "ffmpeg", args...); err != nil {
+		return fmt.Errorf("ffmpeg thumbnail failed: %w", err)
+	}
+	return nil
+}
+
+// createThumbnailRow switches the preview between the platform's thumbnail
+// and a frame from the video itself
+func createThumbnailRow(info Download) []tgbotapi.InlineKeyboardButton {
+	label := "🖼 Preview: platform thumbnail"
+	if info.FrameThumbnail {
+		label = fmt.Sprintf("🖼 Preview: frame at %s", formatDuration(thumbnailFrameSeconds))
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "thumbframe:toggle"))
+}
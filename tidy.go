@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// deleteSourceMessages removes the message a link was sent in once its download
+// starts, when DELETE_SOURCE_MESSAGES is "true", "1" or "on". It keeps group
+// chats tidy and links out of the history.
+var deleteSourceMessages = loadDeleteSourceMessages()
+
+func loadDeleteSourceMessages() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DELETE_SOURCE_MESSAGES"))) {
+	case "true", "1", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// deleteSourceMessage removes the user's link message if configured. In groups
+// the bot needs the "delete messages" admin right; without it Telegram refuses
+// and the message simply stays.
+func deleteSourceMessage(bot *tgbotapi.BotAPI, chatID int64, info Download) {
+	if !deleteSourceMessages || info.SourceMessageID == 0 {
+		return
+	}
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, info.SourceMessageID)); err != nil {
+		jobLog(info).Printf("Couldn't delete the link message in chat %d, the bot may lack permission: %v", chatID, err)
+	}
+}
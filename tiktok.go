@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultTikTokAPIHostnames are the alternate TikTok API hosts tried, in order,
+// after yt-dlp's default extraction fails. TikTok regularly breaks one host or
+// another, so a chain of them succeeds far more often than a single attempt.
+const DefaultTikTokAPIHostnames = "api16-normal-c-useast1a.tiktokv.com,api22-normal-c-useast2a.tiktokv.com,api19-normal-c-useast1a.tiktokv.com"
+
+// tiktokAPIHostnames returns the fallback chain from TIKTOK_API_HOSTNAMES, a
+// comma-separated list; set it to "none" to make a single attempt only
+func tiktokAPIHostnames() []string {
+	value := os.Getenv("TIKTOK_API_HOSTNAMES")
+	if value == "" {
+		value = DefaultTikTokAPIHostnames
+	}
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil
+	}
+
+	var hostnames []string
+	for _, hostname := range strings.Split(value, ",") {
+		if hostname = strings.TrimSpace(hostname); hostname != "" {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
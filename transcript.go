@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxTranscriptLanguages caps how many language buttons are offered
+const MaxTranscriptLanguages = 8
+
+// transcriptLanguages lists the languages a transcript can be made in: manual
+// subtitles first, then the auto-generated captions in the video's own language.
+// YouTube also offers dozens of machine-translated caption tracks; those are left out.
+func transcriptLanguages(meta *VideoMetadata) []string {
+	if meta == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var languages []string
+	add := func(language string) {
+		if !seen[language] && len(languages) < MaxTranscriptLanguages {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+
+	var manual []string
+	for language := range meta.Subtitles {
+		if language != "live_chat" {
+			manual = append(manual, language)
+		}
+	}
+	sort.Strings(manual)
+	for _, language := range manual {
+		add(language)
+	}
+
+	var original []string
+	for language := range meta.AutomaticCaptions {
+		if strings.HasSuffix(language, "-orig") {
+			original = append(original, language)
+		}
+	}
+	sort.Strings(original)
+	for _, language := range original {
+		add(language)
+	}
+	if _, ok := meta.AutomaticCaptions[meta.Language]; ok && meta.Language != "" {
+		add(meta.Language)
+	}
+
+	return languages
+}
+
+// createTranscriptLanguageKeyboard lets the user choose the language of a
+// transcript or subtitle file; format is the callback format the buttons send
+func createTranscriptLanguageKeyboard(format string, languages []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, language := range languages {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🗣 "+language, format+":"+language))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("« Back", "menu:formats"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+var (
+	vttTagPattern       = regexp.MustCompile(`<[^>]*>`)
+	vttTimestampPattern = regexp.MustCompile(`-->`)
+)
+
+// vttToText strips a WebVTT file down to its spoken text. Auto-generated captions
+// repeat each line as it scrolls, so consecutive duplicates are collapsed.
+func vttToText(vtt string) string {
+	var lines []string
+	last := ""
+	for _, line := range strings.Split(vtt, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "",
+			line == "WEBVTT",
+			strings.HasPrefix(line, "Kind:"),
+			strings.HasPrefix(line, "Language:"),
+			strings.HasPrefix(line, "NOTE"),
+			strings.HasPrefix(line, "STYLE"),
+			vttTimestampPattern.MatchString(line):
+			continue
+		}
+
+		line = strings.TrimSpace(vttTagPattern.ReplaceAllString(line, ""))
+		if line == "" || line == last {
+			continue
+		}
+		// Skip numeric cue identifiers
+		if strings.Trim(line, "0123456789") == "" {
+			continue
+		}
+		lines = append(lines, line)
+		last = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleTranscriptDownload fetches the subtitles in the given language, turns
+// them into plain text and sends them as a .txt document
+func handleTranscriptDownload(bot *tgbotapi.BotAPI, runner Runner, chatID int64, info Download, language string, statusMsgID int) {
+	prefix := filePrefix("transcript", fileStamp(info))
+
+	ytdlpArgs := []string{
+		"--skip-download",
+		"--write-subs",
+		"--write-auto-subs",
+		"--sub-format", "vtt",
+		"--sub-langs", language,
+		"-o", prefix + ".%(ext)s",
+		"--no-playlist",
+	}
+
+	output, err := downloadWithClientFallback(bot, runner, chatID, statusMsgID, info, ytdlpArgs, "transcript")
+
+	subtitleFiles, _ := filepath.Glob(prefix + ".*")
+	for _, file := range subtitleFiles {
+		defer os.Remove(file)
+	}
+
+	if err != nil {
+		reportDownloadFailure(bot, chatID, info, "transcript download", err, output)
+		return
+	}
+
+	var vttFile string
+	for _, file := range subtitleFiles {
+		if strings.HasSuffix(file, ".vtt") {
+			vttFile = file
+			break
+		}
+	}
+	if vttFile == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "📄 No transcript is available for this video in that language."))
+		return
+	}
+
+	vtt, err := os.ReadFile(vttFile)
+	if err != nil {
+		jobLog(info).Println("Failed to read subtitles:", err)
+		sendFailure(bot, chatID, "❌ Failed to read the transcript.")
+		return
+	}
+
+	text := vttToText(string(vtt))
+	if text == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "📄 The transcript for this video is empty."))
+		return
+	}
+
+	caption := buildCaption(info.Title, func(title string) string {
+		return fmt.Sprintf("📄 "+bold("Transcript")+" - %s\n▫️ Language: %s", title, escapeText(language))
+	})
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("%s (%s).txt", sanitizeFilename(info.Title), language),
+		Bytes: []byte(text),
+	})
+	document.Caption = caption
+	document.ParseMode = parseMode
+	if _, err := sendWithRetry(bot, document); err != nil {
+		jobLog(info).Println("Failed to send transcript:", err)
+		sendFailure(bot, chatID, "❌ Failed to send the transcript.")
+		return
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, statusMsgID)); err != nil {
+		jobLog(info).Println("Failed to delete the status message:", err)
+	}
+	downloadSucceeded(chatID)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultVideoDocumentThresholdMB is the size above which videos are sent as
+// documents when VIDEO_DOCUMENT_THRESHOLD_MB is unset
+const DefaultVideoDocumentThresholdMB = 50
+
+// videoDocumentThreshold is read from VIDEO_DOCUMENT_THRESHOLD_MB at startup.
+// Telegram's preview generation struggles with large videos and sometimes fails
+// the whole upload, while documents always go through.
+var videoDocumentThreshold = loadVideoDocumentThreshold()
+
+func loadVideoDocumentThreshold() int64 {
+	megabytes, err := strconv.Atoi(os.Getenv("VIDEO_DOCUMENT_THRESHOLD_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = DefaultVideoDocumentThresholdMB
+	}
+	return int64(megabytes) * 1024 * 1024
+}
+
+// sendAsDocument reports whether a video is large enough to go out as a document
+// rather than a streamable video
+func sendAsDocument(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Size() > videoDocumentThreshold
+}
+
+// sendVideoFile uploads a video together with its dimensions, duration and thumbnail.
+// tgbotapi's VideoConfig has no width/height fields, so the sendVideo request is
+// assembled by hand; without them Telegram guesses the aspect ratio and vertical
+// videos end up letterboxed. With asDocument the file goes through sendDocument
+// instead, keeping the thumbnail but skipping the streaming preview.
+func sendVideoFile(bot *tgbotapi.BotAPI, chatID int64, video tgbotapi.RequestFileData, caption string, media MediaInfo, thumbFile string, asDocument bool) (tgbotapi.Message, error) {
+	method, field := "sendVideo", "video"
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", chatID)
+	params.AddNonEmpty("caption", caption)
+	params.AddNonEmpty("parse_mode", parseMode)
+	if asDocument {
+		method, field = "sendDocument", "document"
+	} else {
+		params.AddNonZero("width", media.Width)
+		params.AddNonZero("height", media.Height)
+		params.AddNonZero("duration", media.Duration)
+		params.AddBool("supports_streaming", true)
+	}
+
+	files := []tgbotapi.RequestFile{
+		{Name: field, Data: video},
+	}
+	if thumbFile != "" {
+		files = append(files, tgbotapi.RequestFile{Name: "thumb", Data: tgbotapi.FilePath(thumbFile)})
+	}
+
+	resp, err := bot.UploadFiles(method, params, files)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var message tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &message); err != nil {
+		return message, fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	return message, nil
+}
+
+// progressReader reports how far through a file the upload is. The position is
+// taken from the file itself, so rewinding it for a retry resets the progress.
+type progressReader struct {
+	file     *os.File
+	total    int64
+	onUpdate func(percent int)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	if position, seekErr := r.file.Seek(0, io.SeekCurrent); seekErr == nil && r.total > 0 {
+		r.onUpdate(int(position * 100 / r.total))
+	}
+	return n, err
+}
+
+// openUpload opens a file for upload, editing the status message with the upload
+// percentage as Telegram receives it. The returned file must be closed by the
+// caller, and passed to sendWithRetry so a retry can rewind it.
+func openUpload(bot *tgbotapi.BotAPI, chatID int64, statusMsgID int, path string, info Download) (tgbotapi.RequestFileData, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	lastUpdateTime := time.Now()
+	lastPercent := 0
+	reader := &progressReader{
+		file:  file,
+		total: stat.Size(),
+		onUpdate: func(percent int) {
+			if percent == lastPercent || percent >= 100 || time.Since(lastUpdateTime).Seconds() < UpdateIntervalSec {
+				return
+			}
+			editMsg := tgbotapi.NewEditMessageText(
+				chatID,
+				statusMsgID,
+				buildMessage(info.Title, func(title string) string {
+					return fmt.Sprintf("📤 "+bold("Uploading to Telegram")+"\n\n%s\n\n%d%% uploaded...", title, percent)
+				}),
+			)
+			editMsg.ParseMode = parseMode
+			bot.Send(editMsg)
+
+			lastUpdateTime = time.Now()
+			lastPercent = percent
+		},
+	}
+
+	return tgbotapi.FileReader{Name: uploadFilename(info, path), Reader: reader}, file, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dateFilenames prefixes uploaded file names with the publish date when
+// DATE_FILENAMES is set to "true", "1" or "on", so archives sort chronologically
+var dateFilenames = loadDateFilenames()
+
+func loadDateFilenames() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DATE_FILENAMES"))) {
+	case "true", "1", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// uploadDate parses yt-dlp's YYYYMMDD upload_date, reporting false when the
+// platform didn't provide one
+func uploadDate(meta *VideoMetadata) (time.Time, bool) {
+	if meta == nil || meta.UploadDate == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("20060102", meta.UploadDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// uploadDateNote shows the publish date in a caption, or nothing when it is unknown
+func uploadDateNote(meta *VideoMetadata) string {
+	date, ok := uploadDate(meta)
+	if !ok {
+		return ""
+	}
+	return "\n▫️ Published: " + date.Format("2 January 2006")
+}
+
+// uploadFilename names a file sent to the chat. Normally that is the temp file's
+// own name; with DATE_FILENAMES it becomes "2024-01-31 - Title.ext" when the
+// publish date is known.
+func uploadFilename(info Download, path string) string {
+	date, ok := uploadDate(info.Meta)
+	if !dateFilenames || !ok {
+		return filepath.Base(path)
+	}
+	return date.Format("2006-01-02") + " - " + sanitizeFilename(info.Title) + filepath.Ext(path)
+}
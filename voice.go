@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Limits for sending audio as a voice message. Telegram accepts voice messages
+// up to 50 MB; the duration cap keeps the option to spoken-word clips, where a
+// waveform and playback speed controls make sense.
+const (
+	MaxVoiceSize     = 50 * 1024 * 1024
+	MaxVoiceDuration = 20 * 60 // seconds
+)
+
+// VoiceBitrate is plenty for speech and keeps voice messages small
+const VoiceBitrate = "64K"
+
+// offersVoice reports whether a video is short enough for the voice option
+func offersVoice(meta *VideoMetadata) bool {
+	return meta != nil && meta.Duration > 0 && meta.Duration <= MaxVoiceDuration
+}
+
+// withVoiceFormat switches an MP3 extraction to Opus in an Ogg container, the
+// only format Telegram shows as a voice message
+func withVoiceFormat(args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		result = append(result, args[i])
+		if i+1 >= len(args) {
+			continue
+		}
+		switch args[i] {
+		case "--audio-format":
+			result = append(result, "opus")
+			i++
+		case "--audio-quality":
+			result = append(result, VoiceBitrate)
+			i++
+		}
+	}
+	return result
+}
+
+// newVoiceUpload sends an extracted Opus file as a voice message. Telegram wants
+// the file named .ogg, which is the container yt-dlp's .opus files use anyway.
+func newVoiceUpload(chatID int64, upload tgbotapi.RequestFileData, meta *VideoMetadata, caption string) tgbotapi.VoiceConfig {
+	if reader, ok := upload.(tgbotapi.FileReader); ok {
+		reader.Name = strings.TrimSuffix(reader.Name, ".opus") + ".ogg"
+		upload = reader
+	}
+	voice := tgbotapi.NewVoice(chatID, upload)
+	voice.Caption = caption
+	voice.ParseMode = parseMode
+	if meta != nil {
+		voice.Duration = int(meta.Duration)
+	}
+	return voice
+}
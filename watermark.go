@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WatermarkMargin is the gap, in pixels, between the watermark and the edges
+const WatermarkMargin = 10
+
+// watermarkPositions maps WATERMARK_POSITION values to overlay (image) and
+// drawtext (text) coordinates
+var watermarkPositions = map[string]struct{ overlay, text string }{
+	"top-left":     {"%[1]d:%[1]d", "x=%[1]d:y=%[1]d"},
+	"top-right":    {"W-w-%[1]d:%[1]d", "x=w-tw-%[1]d:y=%[1]d"},
+	"bottom-left":  {"%[1]d:H-h-%[1]d", "x=%[1]d:y=h-th-%[1]d"},
+	"bottom-right": {"W-w-%[1]d:H-h-%[1]d", "x=w-tw-%[1]d:y=h-th-%[1]d"},
+}
+
+// Watermark settings, read at startup. WATERMARK_IMAGE takes precedence over
+// WATERMARK_TEXT; with neither set, videos are sent untouched.
+var (
+	watermarkImage    = os.Getenv("WATERMARK_IMAGE")
+	watermarkText     = os.Getenv("WATERMARK_TEXT")
+	watermarkPosition = loadWatermarkPosition()
+)
+
+func loadWatermarkPosition() string {
+	position := strings.ToLower(strings.TrimSpace(os.Getenv("WATERMARK_POSITION")))
+	if _, ok := watermarkPositions[position]; ok {
+		return position
+	}
+	if position != "" {
+		log.Printf("Unknown WATERMARK_POSITION %q, using bottom-right", position)
+	}
+	return "bottom-right"
+}
+
+// watermarkEnabled reports whether videos should be branded before upload
+func watermarkEnabled() bool {
+	return watermarkImage != "" || watermarkText != ""
+}
+
+// applyWatermark re-encodes a video with the configured logo or text overlaid
+// and returns the path of the branded copy
+func applyWatermark(runner Runner, path string) (string, error) {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	output := base + "_branded.mp4"
+	position := watermarkPositions[watermarkPosition]
+
+	args := []string{"-y", "-v", "error", "-i", path}
+	if watermarkImage != "" {
+		args = append(args,
+			"-i", watermarkImage,
+			"-filter_complex", "[0:v][1:v]overlay="+fmt.Sprintf(position.overlay, WatermarkMargin),
+		)
+	} else {
+		// Passing the text through a file avoids escaping it for the filter syntax
+		textFile := base + "_watermark.txt"
+		if err := os.WriteFile(textFile, []byte(watermarkText), 0o644); err != nil {
+			return "", fmt.Errorf("failed to write watermark text: %w", err)
+		}
+		defer os.Remove(textFile)
+
+		args = append(args,
+			"-vf", fmt.Sprintf("drawtext=textfile=%s:fontcolor=white@0.8:fontsize=h/25:box=1:boxcolor=black@0.4:boxborderw=6:%s",
+				textFile, fmt.Sprintf(position.text, WatermarkMargin)),
+		)
+	}
+	args = append(args,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		output,
+	)
+
+	if _, err := runner.Run(context.Background(), "ffmpeg", args...); err != nil {
+		return "", fmt.Errorf("ffmpeg watermark failed: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// welcomeData is what a custom welcome template can use
+type welcomeData struct {
+	// Platforms is the bulleted list of enabled platforms, one per line
+	Platforms string
+	// PlatformList is the enabled platforms as "A, B, or C"
+	PlatformList string
+	// PlatformNames is the enabled platforms, for templates that lay them out themselves
+	PlatformNames []string
+}
+
+// welcomeTemplate is the operator's welcome text, from WELCOME_FILE or else
+// WELCOME_TEXT, or nil to use the built-in one. The text is a Go template, e.g.
+// "Hi from ACME!\n\nWe support:\n{{.Platforms}}\nHelp: @acme_support",
+// formatted according to PARSE_MODE.
+var welcomeTemplate = loadWelcomeTemplate()
+
+func loadWelcomeTemplate() *template.Template {
+	text := os.Getenv("WELCOME_TEXT")
+	if path := os.Getenv("WELCOME_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read WELCOME_FILE, using the default welcome: %v", err)
+			return nil
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	// Env vars can't easily hold newlines, so a literal \n stands for one
+	text = strings.ReplaceAll(text, `\n`, "\n")
+	tmpl, err := template.New("welcome").Parse(text)
+	if err != nil {
+		log.Printf("Invalid welcome template, using the default welcome: %v", err)
+		return nil
+	}
+	return tmpl
+}
+
+// welcomeText is shown on /start and /help
+func welcomeText() string {
+	var list strings.Builder
+	for _, platform := range enabledPlatforms {
+		list.WriteString("• " + platform + "\n")
+	}
+
+	if welcomeTemplate != nil {
+		var text strings.Builder
+		err := welcomeTemplate.Execute(&text, welcomeData{
+			Platforms:     list.String(),
+			PlatformList:  enabledPlatformList(),
+			PlatformNames: enabledPlatforms,
+		})
+		if err == nil {
+			return text.String()
+		}
+		log.Println("Failed to render the welcome template:", err)
+	}
+
+	return "🚀 " + bold("Media Downloader") + "\n\n" +
+		"Send any link from these platforms:\n" +
+		list.String() +
+		"\nI'll download the video or audio for you!\n\n" +
+		"Use /layout to switch between compact and detailed download buttons."
+}
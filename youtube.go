@@ -0,0 +1,137 @@
+package main
+
+import (
+	neturl "net/url"
+	"os"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultPlayerClients is the order in which YouTube player clients are tried.
+// The android and tv clients are often served age-restricted videos that the
+// web client refuses without a login.
+const DefaultPlayerClients = "android,tv,web"
+
+// ageRestrictedHint is shown when every player client was refused on age grounds
+const ageRestrictedHint = "🔞 This video is age-restricted and YouTube refused every download method we tried.\n\n" +
+	"The bot operator can enable these downloads by giving yt-dlp a logged-in YouTube cookies file (--cookies)."
+
+// youtubePlayerClients returns the configured fallback chain of player clients
+func youtubePlayerClients() []string {
+	value := os.Getenv("YOUTUBE_PLAYER_CLIENTS")
+	if value == "" {
+		value = DefaultPlayerClients
+	}
+
+	var clients []string
+	for _, client := range strings.Split(value, ",") {
+		if client = strings.TrimSpace(client); client != "" {
+			clients = append(clients, client)
+		}
+	}
+	if len(clients) == 0 {
+		// Nothing usable configured, let yt-dlp pick its own client
+		clients = []string{""}
+	}
+	return clients
+}
+
+// isAgeRestricted reports whether yt-dlp's output says the video is age-gated
+func isAgeRestricted(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "confirm your age") ||
+		strings.Contains(lower, "age-restricted") ||
+		strings.Contains(lower, "age restricted") ||
+		strings.Contains(lower, "inappropriate for some users")
+}
+
+// isBotCheck reports whether YouTube wanted proof that the request came from a
+// person, which it asks some player clients for and not others
+func isBotCheck(output string) bool {
+	return strings.Contains(strings.ToLower(output), "confirm you're not a bot")
+}
+
+// downloadWithClientFallback runs a download and retries it with alternate
+// extractor settings: for YouTube the next player client whenever the previous
+// one was refused on age grounds or by a bot check, for TikTok the next API hostname whenever the
+// previous attempt failed for a reason other than the video itself.
+// args must not contain the URL; it is appended for each attempt.
+func downloadWithClientFallback(bot *tgbotapi.BotAPI, runner Runner, chatID int64, statusMsgID int, info Download, args []string, quality string) (string, error) {
+	// Each entry is an --extractor-args value, "" meaning yt-dlp's defaults
+	attempts := []string{""}
+	retry := func(output string, err error) bool { return false }
+	switch info.Platform {
+	case "YouTube":
+		attempts = nil
+		for _, client := range youtubePlayerClients() {
+			if client != "" {
+				client = "youtube:player_client=" + client
+			}
+			attempts = append(attempts, client)
+		}
+		retry = func(output string, err error) bool { return isAgeRestricted(output) || isBotCheck(output) }
+	case "TikTok":
+		for _, hostname := range tiktokAPIHostnames() {
+			attempts = append(attempts, "tiktok:api_hostname="+hostname)
+		}
+		retry = func(output string, err error) bool {
+			failure, _ := classifyFailure(err, output)
+			return failure.Kind != UserFailure
+		}
+	}
+
+	var output string
+	var err error
+	for i, attempt := range attempts {
+		attemptArgs := append([]string{}, args...)
+		if attempt != "" {
+			attemptArgs = append(attemptArgs, "--extractor-args", attempt)
+		}
+		attemptArgs = withExtraArgs(attemptArgs, info.URL)
+
+		output, err = runDownload(bot, runner, chatID, statusMsgID, info, attemptArgs, quality)
+		if err == nil || i == len(attempts)-1 || !retry(output, err) {
+			if err == nil && i > 0 {
+				jobLog(info).Printf("debug: %s download succeeded with fallback %q", info.Platform, attempt)
+			}
+			recordDownloadResult(info.Platform, err, output)
+			return output, err
+		}
+		jobLog(info).Printf("Extractor settings %q failed for %s, trying the next ones", attempt, info.URL)
+	}
+
+	return output, err
+}
+
+// isYouTubeVideoURL reports whether a YouTube link points at a single video,
+// as opposed to a channel page, community post or other non-video page
+func isYouTubeVideoURL(rawURL string) bool {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	path := parsed.Path
+
+	if host == "youtu.be" {
+		return len(strings.Trim(path, "/")) > 0
+	}
+
+	switch {
+	case path == "/watch":
+		return parsed.Query().Get("v") != ""
+	case strings.HasPrefix(path, "/shorts/"),
+		strings.HasPrefix(path, "/live/"),
+		strings.HasPrefix(path, "/embed/"),
+		strings.HasPrefix(path, "/v/"):
+		return true
+	}
+	return false
+}
+
+// nonVideoYouTubeMessage explains why a YouTube link can't be downloaded
+const nonVideoYouTubeMessage = "📝 This YouTube link isn't a downloadable video. " +
+	"It looks like a channel page, community post or other non-video page.\n\n" +
+	"Please send a link to a specific video instead."
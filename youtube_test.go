@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// playerClient returns the YouTube player client an attempt asked for
+func playerClient(args []string) string {
+	value, _ := flagValue(args, "--extractor-args")
+	return strings.TrimPrefix(value, "youtube:player_client=")
+}
+
+func TestYouTubeClientFallback(t *testing.T) {
+	const (
+		ageGate  = "ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users."
+		botCheck = "ERROR: [youtube] abc: Sign in to confirm you're not a bot. This helps protect our community."
+		removed  = "ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader"
+	)
+
+	tests := []struct {
+		name     string
+		failures map[string]string // output per player client; others succeed
+		clients  []string
+		wantErr  bool
+	}{
+		{"first client works", nil, []string{"android"}, false},
+		{"age gate, next client works", map[string]string{"android": ageGate}, []string{"android", "tv"}, false},
+		{"bot check, next client works", map[string]string{"android": botCheck}, []string{"android", "tv"}, false},
+		{"mixed refusals", map[string]string{"android": ageGate, "tv": botCheck}, []string{"android", "tv", "web"}, false},
+		{"every client age-gated", map[string]string{"android": ageGate, "tv": ageGate, "web": ageGate}, []string{"android", "tv", "web"}, true},
+		{"other errors aren't retried", map[string]string{"android": removed}, []string{"android"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("YOUTUBE_PLAYER_CLIENTS", "android,tv,web")
+			runner := &fakeRunner{respond: func(call fakeCall) (string, error) {
+				if output, ok := tt.failures[playerClient(call.args)]; ok {
+					return output, errors.New("exit status 1")
+				}
+				return "", nil
+			}}
+
+			info := Download{Platform: "YouTube", URL: "https://www.youtube.com/watch?v=abc"}
+			output, err := downloadWithClientFallback(newTestBot(t), runner, 1, 1, info, []string{"-f", "best"}, "best")
+
+			var clients []string
+			for _, args := range runner.commands("yt-dlp") {
+				if args[len(args)-1] != info.URL {
+					t.Errorf("URL isn't the last argument: %q", args)
+				}
+				clients = append(clients, playerClient(args))
+			}
+			if strings.Join(clients, ",") != strings.Join(tt.clients, ",") {
+				t.Errorf("tried clients %q, want %q", clients, tt.clients)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && output == "" {
+				t.Error("the failed attempt's output was lost")
+			}
+		})
+	}
+}
+
+func TestAgeRestrictedHint(t *testing.T) {
+	output := "ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users."
+	failure, _ := classifyFailure(errors.New("exit status 1"), output)
+	if failure.Kind != UserFailure || failure.Message != ageRestrictedHint {
+		t.Errorf("classifyFailure = %+v, want the cookies hint", failure)
+	}
+}